@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"hn/hn"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ageFlag is an age such as 6h, 2d or 1w, days and weeks are not Go durations
+type ageFlag struct {
+	value string
+	age   time.Duration
+}
+
+func (f *ageFlag) String() string {
+	return f.value
+}
+
+func (f *ageFlag) Set(value string) error {
+	age, err := parseAge(value)
+	if err != nil {
+		return err
+	}
+
+	f.value, f.age = value, age
+	return nil
+}
+
+func parseAge(value string) (time.Duration, error) {
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+
+	var age time.Duration
+	for unit, length := range units {
+		if n, err := strconv.Atoi(strings.TrimSuffix(value, unit)); err == nil && strings.HasSuffix(value, unit) {
+			age = time.Duration(n) * length
+		}
+	}
+
+	if age == 0 {
+		var err error
+		if age, err = time.ParseDuration(value); err != nil {
+			return 0, fmt.Errorf("invalid age %q, e.g. 90m, 6h, 2d or 1w", value)
+		}
+	}
+
+	if age <= 0 {
+		return 0, fmt.Errorf("age %q must be positive", value)
+	}
+
+	return age, nil
+}
+
+// parseTime parses a point in time given as RFC 3339 or unix seconds
+func parseTime(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, e.g. 2006-01-02T15:04:05Z or 1136214245", value)
+	}
+
+	return t, nil
+}
+
+// filterAge keeps the posts submitted after newerThan and before olderThan,
+// either of which may be zero to not bound it. Posts without a time are dropped.
+func filterAge(posts hn.Posts, newerThan time.Time, olderThan time.Time) hn.Posts {
+	filtered := make(hn.Posts, 0, len(posts))
+	for _, post := range posts {
+		if post.Time.IsZero() {
+			continue
+		}
+		if !newerThan.IsZero() && !post.Time.After(newerThan) {
+			continue
+		}
+		if !olderThan.IsZero() && !post.Time.Before(olderThan) {
+			continue
+		}
+		filtered = append(filtered, post)
+	}
+
+	return filtered
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"hn/hn"
+	"html"
+	"io"
+	"time"
+)
+
+// opml is an outline of links, as feed readers import and export them
+type opml struct {
+	XMLName xml.Name      `xml:"opml"`
+	Version string        `xml:"version,attr"`
+	Title   string        `xml:"head>title"`
+	Created string        `xml:"head>dateCreated"`
+	Links   []opmlOutline `xml:"body>outline"`
+}
+
+type opmlOutline struct {
+	Type    string `xml:"type,attr"`
+	Text    string `xml:"text,attr"`
+	URL     string `xml:"url,attr"`
+	Created string `xml:"created,attr,omitempty"`
+}
+
+func writeOPML(w io.Writer, posts hn.Posts) error {
+	doc := opml{Version: "2.0", Title: "Hacker News", Created: time.Now().UTC().Format(time.RFC1123Z)}
+
+	for _, post := range posts {
+		u, err := client.StoryURL(post)
+		if err != nil {
+			return err
+		}
+
+		outline := opmlOutline{Type: "link", Text: post.Title, URL: u}
+		if !post.Time.IsZero() {
+			outline.Created = post.Time.Format(time.RFC1123Z)
+		}
+		doc.Links = append(doc.Links, outline)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "    ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// writeBookmarks writes the bookmark file every browser imports, a folder
+// with a link to each story
+func writeBookmarks(w io.Writer, posts hn.Posts) error {
+	_, err := fmt.Fprintf(w, "<!DOCTYPE NETSCAPE-Bookmark-file-1>\n"+
+		"<META HTTP-EQUIV=\"Content-Type\" CONTENT=\"text/html; charset=UTF-8\">\n"+
+		"<TITLE>Bookmarks</TITLE>\n<H1>Bookmarks</H1>\n<DL><p>\n"+
+		"    <DT><H3 ADD_DATE=\"%d\">Hacker News</H3>\n    <DL><p>\n", time.Now().Unix())
+	if err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		u, err := client.StoryURL(post)
+		if err != nil {
+			return err
+		}
+
+		added := ""
+		if !post.Time.IsZero() {
+			added = fmt.Sprintf(" ADD_DATE=\"%d\"", post.Time.Unix())
+		}
+
+		_, err = fmt.Fprintf(w, "        <DT><A HREF=\"%s\"%s>%s</A>\n", html.EscapeString(u), added, html.EscapeString(post.Title))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprint(w, "    </DL><p>\n</DL><p>\n")
+	return err
+}
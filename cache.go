@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The disk cache keeps its pages in a directory of their own within
+// -cache-dir, each in a file named by the prefix and starting with the magic
+// line. It only ever removes files with both, so pointing -cache-dir at a
+// directory with other files in it, such as ~/.cache, leaves them be.
+const (
+	diskCacheSubdir = "pages"
+	diskCachePrefix = "page-"
+	diskCacheMagic  = "hn cached page\n"
+)
+
+// A diskCache keeps pages in a directory for ttl, a file each, so they
+// outlive a run. It suits commands run again and again, such as from scripts.
+// A file starts with the magic line and the url of its page, on a line of its
+// own, and was modified when the page was fetched.
+type diskCache struct {
+	dir string
+	ttl time.Duration
+}
+
+// newDiskCache keeps pages in dir for ttl, removing those that have expired since it was last used
+func newDiskCache(dir string, ttl time.Duration) (*diskCache, error) {
+	dir = filepath.Join(dir, diskCacheSubdir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	d := &diskCache{dir: dir, ttl: ttl}
+	d.remove(func(url string, fetched time.Time) bool { return time.Since(fetched) > ttl })
+	return d, nil
+}
+
+// path is the file of a url, named by its hash as urls are not file names
+func (d *diskCache) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(d.dir, diskCachePrefix+hex.EncodeToString(sum[:]))
+}
+
+func (d *diskCache) Get(url string) ([]byte, time.Time, bool) {
+	path := d.path(url)
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) > d.ttl {
+		return nil, time.Time{}, false
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+
+	data, ok := bytes.CutPrefix(data, []byte(diskCacheMagic))
+	if !ok {
+		return nil, time.Time{}, false
+	}
+
+	cached, page, ok := bytes.Cut(data, []byte("\n"))
+	if !ok || string(cached) != url {
+		return nil, time.Time{}, false
+	}
+	return page, info.ModTime(), true
+}
+
+func (d *diskCache) Put(url string, page []byte, fetched time.Time) {
+	path := d.path(url)
+
+	// Write then rename, so another run never reads a page half written
+	tmp := path + ".tmp"
+	data := append([]byte(diskCacheMagic+url+"\n"), page...)
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return
+	}
+	os.Chtimes(tmp, fetched, fetched)
+	os.Rename(tmp, path)
+}
+
+func (d *diskCache) Invalidate(prefix string) {
+	d.remove(func(url string, fetched time.Time) bool { return strings.HasPrefix(url, prefix) })
+}
+
+// remove removes the pages remove is true for, files the cache did not write are skipped
+func (d *diskCache) remove(remove func(url string, fetched time.Time) bool) {
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		// Pages being written by another run are not theirs to remove yet
+		name := entry.Name()
+		if !strings.HasPrefix(name, diskCachePrefix) || strings.HasSuffix(name, ".tmp") {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+
+		path := filepath.Join(d.dir, name)
+		url, ok := readCachedURL(path)
+		if ok && remove(url, info.ModTime()) {
+			os.Remove(path)
+		}
+	}
+}
+
+// readCachedURL is the url of the page cached in a file, false if the cache did not write the file
+func readCachedURL(path string) (string, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(io.LimitReader(f, 64<<10))
+	magic, err := r.ReadString('\n')
+	if err != nil || magic != diskCacheMagic {
+		return "", false
+	}
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSuffix(line, "\n"), true
+}
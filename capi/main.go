@@ -0,0 +1,82 @@
+// Command capi exposes the scraper as a C shared library, so Python, Ruby and
+// Node scripts can call it in-process instead of running the binary.
+//
+//	go build -buildmode=c-shared -o libhn.so ./capi
+//
+// Every function returns JSON which the caller must release with hn_free, e.g.
+// with Python's ctypes
+//
+//	lib = ctypes.CDLL("./libhn.so")
+//	lib.hn_fetch_top.restype = ctypes.c_void_p
+//	p = lib.hn_fetch_top(10)
+//	posts = json.loads(ctypes.string_at(p))
+//	lib.hn_free(p)
+package main
+
+// #include <stdlib.h>
+import "C"
+
+import (
+	"encoding/json"
+	"fmt"
+	"hn/hn"
+	"unsafe"
+)
+
+// failure is returned instead of the result when something goes wrong
+type failure struct {
+	Error string `json:"error"`
+}
+
+func result(value interface{}, err error) *C.char {
+	if err != nil {
+		value = failure{Error: err.Error()}
+	}
+
+	response, err := json.Marshal(value)
+	if err != nil {
+		response, _ = json.Marshal(failure{Error: err.Error()})
+	}
+
+	return C.CString(string(response))
+}
+
+// hn_fetch_top returns the top n posts of the front page as a JSON array,
+// or an object with an error
+//
+//export hn_fetch_top
+func hn_fetch_top(n C.int) *C.char {
+	if n < 1 || n > 100 {
+		return result(nil, fmt.Errorf("posts must be between 1 and 100, inclusive"))
+	}
+
+	return result(hn.DefaultClient.FetchPosts(hn.SectionTop, int(n)))
+}
+
+// hn_fetch_new returns the newest n posts as a JSON array, or an object with an error
+//
+//export hn_fetch_new
+func hn_fetch_new(n C.int) *C.char {
+	if n < 1 || n > 100 {
+		return result(nil, fmt.Errorf("posts must be between 1 and 100, inclusive"))
+	}
+
+	return result(hn.DefaultClient.FetchPosts(hn.SectionNew, int(n)))
+}
+
+// hn_fetch_item returns a story with its comments as a JSON object, or an object with an error
+//
+//export hn_fetch_item
+func hn_fetch_item(id C.int) *C.char {
+	return result(hn.DefaultClient.FetchItem(int(id)))
+}
+
+// hn_free releases a string returned by the other functions
+//
+//export hn_free
+func hn_free(p *C.char) {
+	C.free(unsafe.Pointer(p))
+}
+
+// main is required by -buildmode=c-shared, but never called
+func main() {}
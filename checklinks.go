@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"hn/hn"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultPaywalls are news sites that mostly show a paywall rather than the story
+var defaultPaywalls = stringList{
+	"barrons.com", "bloomberg.com", "economist.com", "ft.com", "hbr.org", "newyorker.com", "nytimes.com",
+	"theatlantic.com", "theinformation.com", "thetimes.co.uk", "washingtonpost.com", "wired.com", "wsj.com",
+}
+
+func runCheckLinks(args []string) error {
+	var postsToFetch int
+	var names stringList
+	var format string
+	var timeout time.Duration
+	var parallel int
+	var paywalls stringList
+	var deadOnly bool
+
+	defaultFormat := "json"
+	if isTerminal(os.Stdout) {
+		defaultFormat = "human"
+	}
+
+	flags := flag.NewFlagSet("check-links", flag.ContinueOnError)
+	flags.IntVar(&postsToFetch, "posts", 30, "How many posts of each section to check. A positive integer <= 100.")
+	flags.Var(&names, "section", "Sections to check, top, new, best, ask, show or jobs (default top)")
+	filters := addFilterFlags(flags)
+	flags.StringVar(&format, "format", defaultFormat, "Output format, json or human (default human in a terminal, otherwise json)")
+	flags.DurationVar(&timeout, "timeout", 10*time.Second, "How long to wait for each link, redirects included")
+	flags.IntVar(&parallel, "parallel", 8, "How many links to check at once")
+	flags.Var(&paywalls, "paywalls", "Domains of paywalled sites, subdomains included (default "+defaultPaywalls.String()+")")
+	flags.BoolVar(&deadOnly, "dead", false, "Only list stories whose link is dead")
+
+	clientOptions := addClientFlags(flags)
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if err := clientOptions.apply(); err != nil {
+		return err
+	}
+
+	if postsToFetch < 1 || postsToFetch > 100 {
+		return errors.New("Posts must be between 1 and 100, inclusive.")
+	}
+
+	if parallel < 1 {
+		return errors.New("parallel must be at least 1")
+	}
+
+	if err := applyFilters(*filters); err != nil {
+		return err
+	}
+
+	if format != "json" && format != "human" {
+		return fmt.Errorf("unknown format %q, must be json or human", format)
+	}
+
+	if len(names) == 0 {
+		names = stringList{"top"}
+	}
+	if len(paywalls) == 0 {
+		paywalls = defaultPaywalls
+	}
+
+	listSections := make([]string, 0, len(names))
+	for _, name := range names {
+		section, err := getSection(name)
+		if err != nil {
+			return err
+		}
+		listSections = append(listSections, section)
+	}
+
+	var posts hn.Posts
+	if len(listSections) > 1 {
+		posts, err = client.FetchSections(listSections, postsToFetch)
+	} else {
+		posts, err = client.FetchPosts(listSections[0], postsToFetch)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Story links are on other sites, so they are checked apart from the HN client and its rate
+	checker := hn.NewEnricher()
+	checker.HTTPClient.Timeout = timeout
+	checker.Parallel = parallel
+	checker.Paywalls = paywalls
+	checker.CheckLinks(posts)
+
+	checked := make(hn.Posts, 0, len(posts))
+	for _, post := range posts {
+		// Text posts are on HN, there is no link to check
+		if post.Link == nil || (deadOnly && !post.Link.Dead) {
+			continue
+		}
+		checked = append(checked, post)
+	}
+
+	if format == "human" {
+		return writeLinks(os.Stdout, checked)
+	}
+	return writeJSON(os.Stdout, checked)
+}
+
+// writeLinks writes the status of each link, where it ended up and what is wrong with it
+func writeLinks(w io.Writer, posts hn.Posts) error {
+	for _, post := range posts {
+		link := post.Link
+
+		status := "---"
+		if link.Status != 0 {
+			status = strconv.Itoa(link.Status)
+		}
+
+		notes := make([]string, 0)
+		if link.Dead {
+			notes = append(notes, "dead")
+		}
+		if link.Paywalled {
+			notes = append(notes, "paywall")
+		}
+
+		line := fmt.Sprintf("%s %-12s %d %s", status, strings.Join(notes, ","), post.ID, post.URL)
+		if link.FinalURL != "" && link.FinalURL != post.URL {
+			line += " -> " + link.FinalURL
+		}
+		if link.Error != "" {
+			line += " (" + link.Error + ")"
+		}
+
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
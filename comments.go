@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hn/hn"
+	"os"
+	"regexp"
+)
+
+// A FlatComment is a comment without its replies, which refer to it as their parent instead
+type FlatComment struct {
+	hn.Comment
+	Parent int
+	Depth  int
+}
+
+// commentOptions select which comments of a thread to output, and how
+type commentOptions struct {
+	match      *regexp.Regexp
+	top        int
+	maxDepth   int
+	flat       bool
+	textFormat string
+}
+
+func runComments(args []string) error {
+	var match string
+	var options commentOptions
+
+	flags := flag.NewFlagSet("comments", flag.ContinueOnError)
+	flags.StringVar(&match, "match", "", "Only include comments whose text matches this regular expression, and their ancestors")
+	flags.IntVar(&options.top, "top", 0, "Only include the first N top level comments and their replies (default all)")
+	flags.IntVar(&options.maxDepth, "max-depth", 0, "Only include comments up to N levels deep, 1 is only top level comments (default all)")
+	flags.BoolVar(&options.flat, "flat", false, "Output a flat list with the parent and depth of each comment, instead of a tree")
+	flags.StringVar(&options.textFormat, "text-format", hn.TextMarkdown, "Format of the comment text, plain, markdown or html")
+
+	clientOptions := addClientFlags(flags)
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if err := clientOptions.apply(); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("usage: hn comments [-match=regexp] [-top=N] [-max-depth=N] [-flat] [-text-format=markdown] <id|url>")
+	}
+
+	if options.top < 0 || options.maxDepth < 0 {
+		return errors.New("top and max-depth must not be negative")
+	}
+
+	if err := hn.ValidTextFormat(options.textFormat); err != nil {
+		return err
+	}
+
+	id, err := hn.ParseItemID(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if match != "" {
+		options.match, err = regexp.Compile(match)
+		if err != nil {
+			return fmt.Errorf("invalid match: %v", err)
+		}
+	}
+
+	item, err := client.FetchItem(id)
+	if err != nil {
+		return err
+	}
+
+	response, err := json.MarshalIndent(selectComments(item.Replies, options), "", "    ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(os.Stdout, string(response))
+	return err
+}
+
+// selectComments applies the options, returning either a tree or a flat list
+func selectComments(comments []*hn.Comment, options commentOptions) interface{} {
+	if options.match != nil {
+		comments = filterComments(comments, options.match)
+	}
+
+	if options.top > 0 && len(comments) > options.top {
+		comments = comments[:options.top]
+	}
+
+	if options.maxDepth > 0 {
+		comments = limitDepth(comments, options.maxDepth)
+	}
+
+	// Matching is done on the text as served, so convert it last
+	hn.FormatCommentText(comments, options.textFormat)
+
+	if options.flat {
+		return flattenComments(comments, 0, 1, make([]FlatComment, 0))
+	}
+
+	return comments
+}
+
+// limitDepth copies the tree without the replies deeper than depth
+func limitDepth(comments []*hn.Comment, depth int) []*hn.Comment {
+	limited := make([]*hn.Comment, len(comments))
+
+	for i, comment := range comments {
+		copied := *comment
+		if depth > 1 {
+			copied.Replies = limitDepth(comment.Replies, depth-1)
+		} else {
+			copied.Replies = nil
+		}
+		limited[i] = &copied
+	}
+
+	return limited
+}
+
+// flattenComments lists the tree depth first, which is the order the page shows it in
+func flattenComments(comments []*hn.Comment, parent int, depth int, flat []FlatComment) []FlatComment {
+	for _, comment := range comments {
+		copied := *comment
+		copied.Replies = nil
+
+		flat = append(flat, FlatComment{Comment: copied, Parent: parent, Depth: depth})
+		flat = flattenComments(comment.Replies, comment.ID, depth+1, flat)
+	}
+
+	return flat
+}
+
+// filterComments prunes the tree to the comments whose text matches, keeping the
+// ancestors of each match for context. The tree is copied rather than modified.
+func filterComments(comments []*hn.Comment, re *regexp.Regexp) []*hn.Comment {
+	filtered := make([]*hn.Comment, 0)
+
+	for _, comment := range comments {
+		replies := filterComments(comment.Replies, re)
+		if len(replies) == 0 && !re.MatchString(hn.PlainText(comment.Text)) {
+			continue
+		}
+
+		pruned := *comment
+		pruned.Replies = replies
+		filtered = append(filtered, &pruned)
+	}
+
+	return filtered
+}
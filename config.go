@@ -0,0 +1,514 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The config file sets the defaults of flags. Each section is a command, hn
+// for listing posts, and each key is one of its flags, e.g.
+//
+//	{
+//	    "defaults": {"rate": "30rpm"},
+//	    "watch": {"every": "5m", "min-points": 100, "sink": ["bell", "env:HN_XMPP"]}
+//	}
+//
+// Flags in defaults apply to every command that has them. Flags given on the
+// command line override the config, lists such as sinks add to it.
+
+// configDefaults is the section for flags shared by commands
+const configDefaults = "defaults"
+
+// configFlagSet is the flag set name of listing posts, which is not a sub command
+const configFlagSet = "hn"
+
+// configIgnored are the commands that do not read the config
+var configIgnored = map[string]bool{"config": true, "init": true, "logout": true, "schema": true}
+
+type configValue struct {
+	values []string
+	line   int
+	column int
+}
+
+type configSection struct {
+	flags map[string]configValue
+}
+
+type config struct {
+	path     string
+	sections map[string]configSection
+}
+
+// A configError points at where in the config file the problem is
+type configError struct {
+	path    string
+	line    int
+	column  int
+	message string
+}
+
+func (e *configError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.path, e.line, e.column, e.message)
+}
+
+// configPath is $HN_CONFIG, otherwise hn/config.json in the user's config directory
+func configPath() (path string, required bool) {
+	if path := os.Getenv("HN_CONFIG"); path != "" {
+		return path, true
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", false
+	}
+
+	return filepath.Join(dir, "hn", "config.json"), false
+}
+
+// loadedConfig is read once, by the first command to parse its flags
+var loadedConfig *config
+
+func loadConfig() (*config, error) {
+	if loadedConfig != nil {
+		return loadedConfig, nil
+	}
+
+	path, required := configPath()
+	if path == "" {
+		loadedConfig = &config{}
+		return loadedConfig, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && !required {
+		loadedConfig = &config{path: path}
+		return loadedConfig, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	c, err := parseConfig(path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	loadedConfig = c
+	return c, nil
+}
+
+// parseConfig reads the config a token at a time, to know where each key and value is
+func parseConfig(path string, data []byte) (*config, error) {
+	c := &config{path: path, sections: make(map[string]configSection)}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	errorAt := func(offset int64, format string, args ...interface{}) error {
+		line, column := configPosition(data, offset)
+		return &configError{path, line, column, fmt.Sprintf(format, args...)}
+	}
+
+	// syntaxError locates the errors of the decoder, which only know the offset
+	syntaxError := func(err error) error {
+		var syntax *json.SyntaxError
+		if errors.As(err, &syntax) {
+			return errorAt(syntax.Offset-1, "%v", err)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return errorAt(int64(len(data)), "unexpected end of file")
+		}
+		return errorAt(decoder.InputOffset(), "%v", err)
+	}
+
+	// start is where the next token begins, the decoder's offset is where the last ended
+	start := func() int64 {
+		offset := decoder.InputOffset()
+		for offset < int64(len(data)) && strings.IndexByte(" \t\r\n:,", data[offset]) >= 0 {
+			offset++
+		}
+		return offset
+	}
+
+	offset := start()
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, syntaxError(err)
+	}
+	if token != json.Delim('{') {
+		return nil, errorAt(offset, "config must be an object of commands")
+	}
+
+	for decoder.More() {
+		offset := start()
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, syntaxError(err)
+		}
+
+		name := token.(string)
+		if _, ok := c.sections[name]; ok {
+			return nil, errorAt(offset, "duplicate section %q", name)
+		}
+
+		if name != configDefaults && name != configFlagSet {
+			if _, ok := commands[name]; !ok || configIgnored[name] {
+				return nil, errorAt(offset, "unknown section %q, must be defaults, hn or a command such as watch", name)
+			}
+		}
+
+		offset = start()
+		token, err = decoder.Token()
+		if err != nil {
+			return nil, syntaxError(err)
+		}
+		if token != json.Delim('{') {
+			return nil, errorAt(offset, "section %q must be an object of flags", name)
+		}
+
+		section := configSection{flags: make(map[string]configValue)}
+		for decoder.More() {
+			offset := start()
+			token, err := decoder.Token()
+			if err != nil {
+				return nil, syntaxError(err)
+			}
+
+			key := token.(string)
+			if _, ok := section.flags[key]; ok {
+				return nil, errorAt(offset, "duplicate flag %q in %s", key, name)
+			}
+
+			offset = start()
+			values, err := parseConfigValue(decoder)
+			if err != nil {
+				if _, ok := err.(configValueError); ok {
+					return nil, errorAt(offset, "flag %q in %s %v", key, name, err)
+				}
+				return nil, syntaxError(err)
+			}
+
+			line, column := configPosition(data, offset)
+			section.flags[key] = configValue{values: values, line: line, column: column}
+		}
+
+		// The end of the section
+		if _, err := decoder.Token(); err != nil {
+			return nil, syntaxError(err)
+		}
+
+		c.sections[name] = section
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return nil, syntaxError(err)
+	}
+
+	if offset := start(); offset < int64(len(data)) {
+		return nil, errorAt(offset, "unexpected data after the config")
+	}
+
+	return c, nil
+}
+
+type configValueError string
+
+func (e configValueError) Error() string {
+	return string(e)
+}
+
+// parseConfigValue reads a flag, a string, number or boolean, or a list of them for repeated flags
+func parseConfigValue(decoder *json.Decoder) ([]string, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	if token != json.Delim('[') {
+		value, err := configScalar(token)
+		if err != nil {
+			return nil, err
+		}
+		return []string{value}, nil
+	}
+
+	values := make([]string, 0)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := configScalar(token)
+		if err != nil {
+			return nil, configValueError("must be a list of strings, numbers or booleans")
+		}
+		values = append(values, value)
+	}
+
+	// The end of the list
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func configScalar(token json.Token) (string, error) {
+	switch value := token.(type) {
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	case bool:
+		if value {
+			return "true", nil
+		}
+		return "false", nil
+	}
+
+	return "", configValueError("must be a string, number, boolean or a list of them")
+}
+
+// configPosition converts a byte offset to a line and column, both starting at 1
+func configPosition(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+
+	return line, column
+}
+
+// apply sets the flags of a command from its section, and the shared defaults
+// it has, reporting every problem rather than only the first
+func (c *config) apply(flags *flag.FlagSet) error {
+	problems := make([]string, 0)
+
+	if defaults, ok := c.sections[configDefaults]; ok {
+		for _, name := range sortedFlags(defaults) {
+			if flags.Lookup(name) == nil {
+				continue
+			}
+
+			if configChecked != nil {
+				configChecked.defaults[name] = true
+			}
+
+			if err := c.set(flags, configDefaults, name, defaults.flags[name]); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+	}
+
+	if section, ok := c.sections[flags.Name()]; ok {
+		for _, name := range sortedFlags(section) {
+			value := section.flags[name]
+			if flags.Lookup(name) == nil {
+				problems = append(problems, (&configError{c.path, value.line, value.column, fmt.Sprintf("unknown flag %q for %s", name, flags.Name())}).Error())
+				continue
+			}
+
+			if err := c.set(flags, flags.Name(), name, value); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "\n"))
+	}
+
+	return nil
+}
+
+func (c *config) set(flags *flag.FlagSet, section string, name string, value configValue) error {
+	for _, v := range value.values {
+		if err := flags.Set(name, v); err != nil {
+			return &configError{c.path, value.line, value.column, fmt.Sprintf("invalid value %q for %s %s: %v", v, section, name, err)}
+		}
+	}
+	return nil
+}
+
+func sortedFlags(section configSection) []string {
+	names := make([]string, 0, len(section.flags))
+	for name := range section.flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// configCheck collects the resolved flags of every command, instead of running them
+type configCheck struct {
+	defaults map[string]bool
+	resolved map[string]map[string]string
+}
+
+// configChecked is set while checking the config
+var configChecked *configCheck
+
+// errConfigChecked stops a command once its flags are resolved
+var errConfigChecked = errors.New("config checked")
+
+// parseFlags parses the command line over the defaults from the config
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	c, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if err := c.apply(flags); err != nil {
+		return err
+	}
+
+	if configChecked != nil {
+		resolved := make(map[string]string)
+		flags.VisitAll(func(f *flag.Flag) {
+			resolved[f.Name] = redactValue(f.Value.String())
+		})
+		configChecked.resolved[flags.Name()] = resolved
+		return errConfigChecked
+	}
+
+	return flags.Parse(args)
+}
+
+// redactValue hides the passwords of the URLs in a flag, which may be a list
+func redactValue(value string) string {
+	values := strings.Split(value, ",")
+	for i, v := range values {
+		if strings.Contains(v, "://") {
+			values[i] = redactURL(v)
+		}
+	}
+	return strings.Join(values, ",")
+}
+
+func runConfig(args []string) error {
+	var resolved bool
+
+	if len(args) == 0 || args[0] != "check" && args[0] != "show" {
+		return errors.New("usage: hn config check | hn config show [-resolved]")
+	}
+
+	flags := flag.NewFlagSet("config "+args[0], flag.ContinueOnError)
+	if args[0] == "show" {
+		flags.BoolVar(&resolved, "resolved", false, "Show every flag of every command, with the config applied")
+	}
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	c, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	if args[0] == "show" && !resolved {
+		if c.path == "" {
+			return errors.New("there is no config directory, set HN_CONFIG")
+		}
+
+		data, err := os.ReadFile(c.path)
+		if os.IsNotExist(err) {
+			fmt.Printf("# %s does not exist\n", c.path)
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		fmt.Printf("# %s\n%s", c.path, data)
+		return nil
+	}
+
+	check, err := checkConfig(c)
+	if err != nil {
+		return err
+	}
+
+	if args[0] == "check" {
+		if c.path != "" {
+			fmt.Printf("%s is valid\n", c.path)
+		}
+		return nil
+	}
+
+	response, err := json.MarshalIndent(check.resolved, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Println(string(response))
+	return err
+}
+
+// checkConfig applies the config to the flags of every command, without running them
+func checkConfig(c *config) (*configCheck, error) {
+	loadedConfig = c
+	configChecked = &configCheck{defaults: make(map[string]bool), resolved: make(map[string]map[string]string)}
+	defer func() {
+		configChecked = nil
+	}()
+
+	names := []string{configFlagSet}
+	for name := range commands {
+		if !configIgnored[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	problems := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, name := range names {
+		run := runList
+		if name != configFlagSet {
+			run = commands[name]
+		}
+
+		// A command that returns nil ran rather than stopping at its flags, which is a bug in hn
+		err := run(nil)
+		if err == nil {
+			err = fmt.Errorf("command %s ran rather than stopping once its flags were resolved", name)
+		}
+
+		// Problems with defaults are found by every command with the flag, they are only reported once
+		if err != errConfigChecked {
+			for _, problem := range strings.Split(err.Error(), "\n") {
+				if !seen[problem] {
+					seen[problem] = true
+					problems = append(problems, problem)
+				}
+			}
+		}
+	}
+
+	if defaults, ok := c.sections[configDefaults]; ok {
+		for _, name := range sortedFlags(defaults) {
+			if !configChecked.defaults[name] {
+				value := defaults.flags[name]
+				problems = append(problems, (&configError{c.path, value.line, value.column, fmt.Sprintf("unknown flag %q, no command has it", name)}).Error())
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, errors.New(strings.Join(problems, "\n"))
+	}
+
+	return configChecked, nil
+}
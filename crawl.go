@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"hn/hn"
+	"log/slog"
+	"time"
+)
+
+// dayFormat is how days are given on the command line, and in the urls of HN
+const dayFormat = "2006-01-02"
+
+func runCrawl(args []string) error {
+	var name string
+	var from string
+	var to string
+	var storeURL string
+	var postsToFetch int
+
+	yesterday := time.Now().UTC().AddDate(0, 0, -1).Format(dayFormat)
+
+	flags := flag.NewFlagSet("crawl", flag.ContinueOnError)
+	flags.StringVar(&name, "section", "front", "Section to crawl by day, only front has pages of past days")
+	flags.StringVar(&from, "from", "", "The first day to crawl, e.g. 2020-01-01")
+	flags.StringVar(&to, "to", yesterday, "The last day to crawl, inclusive (default yesterday)")
+	flags.StringVar(&storeURL, "store", "", "Where to keep a snapshot of each day, e.g. file:///var/lib/hn")
+	flags.IntVar(&postsToFetch, "posts", 30, "How many posts to keep of each day. A positive integer <= 100.")
+
+	clientOptions := addClientFlags(flags)
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if err := clientOptions.apply(); err != nil {
+		return err
+	}
+
+	if name != "front" {
+		return fmt.Errorf("unknown section %q, only front has pages of past days", name)
+	}
+
+	if postsToFetch < 1 || postsToFetch > 100 {
+		return errors.New("posts must be between 1 and 100, inclusive")
+	}
+
+	if from == "" || storeURL == "" {
+		return errors.New("usage: hn crawl -from=2020-01-01 [-to=2020-12-31] -store=file:///var/lib/hn")
+	}
+
+	first, err := time.Parse(dayFormat, from)
+	if err != nil {
+		return fmt.Errorf("from must be a day such as 2020-01-01: %v", err)
+	}
+
+	last, err := time.Parse(dayFormat, to)
+	if err != nil {
+		return fmt.Errorf("to must be a day such as 2020-12-31: %v", err)
+	}
+
+	if last.Before(first) {
+		return errors.New("to must not be before from")
+	}
+
+	if !last.Before(time.Now().UTC().Truncate(24 * time.Hour)) {
+		return errors.New("to must be a past day, today is not over yet")
+	}
+
+	s, err := openStore(storeURL)
+	if err != nil {
+		return err
+	}
+
+	return crawl(s, first, last, postsToFetch)
+}
+
+// crawl snapshots the front page of every day from first to last. The snapshot
+// of a day is its checkpoint, saved whole once all of its pages are fetched,
+// so a crawl that is run again skips the days it already has and continues
+// with the one it was interrupted on.
+func crawl(s store, first time.Time, last time.Time, postsToFetch int) error {
+	snapshots, err := s.List(hn.SectionFront)
+	if err != nil {
+		return err
+	}
+
+	crawled := make(map[string]bool, len(snapshots))
+	for _, snapshot := range snapshots {
+		crawled[snapshot.ID] = true
+	}
+
+	days := make([]time.Time, 0)
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		if !crawled[snapshotID(hn.SectionFront, day)] {
+			days = append(days, day)
+		}
+	}
+
+	total := int(last.Sub(first).Hours()/24) + 1
+	if skipped := total - len(days); skipped > 0 {
+		slog.Info("resuming crawl", "crawled", skipped, "days", total)
+	}
+
+	start := time.Now()
+	for i, day := range days {
+		posts, err := client.FetchDay(day, postsToFetch)
+		if err != nil {
+			return fmt.Errorf("crawling %s: %w", day.Format(dayFormat), err)
+		}
+
+		err = s.Save(Snapshot{
+			ID:      snapshotID(hn.SectionFront, day),
+			Section: hn.SectionFront,
+			Time:    day,
+			Posts:   posts,
+		})
+		if err != nil {
+			return err
+		}
+
+		// The rate of the client sets the pace, so the days left take about as long as those so far
+		done := i + 1
+		left := time.Duration(float64(time.Since(start)) / float64(done) * float64(len(days)-done))
+		slog.Info("crawled day", "day", day.Format(dayFormat), "posts", len(posts), "left", len(days)-done,
+			"eta", left.Round(time.Second))
+	}
+
+	slog.Info("crawl done", "days", total, "from", first.Format(dayFormat), "to", last.Format(dayFormat))
+	return nil
+}
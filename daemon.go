@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+func runDaemon(args []string) error {
+	var every time.Duration
+	var jitter time.Duration
+	var retention time.Duration
+	var storeURL string
+	var listen string
+	var postsToFetch int
+	var names stringList
+
+	flags := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	flags.DurationVar(&every, "every", 10*time.Minute, "How often to snapshot the sections")
+	flags.DurationVar(&jitter, "jitter", time.Minute, "Up to how long to randomly delay each snapshot, so runs do not line up")
+	flags.DurationVar(&retention, "retention", 30*24*time.Hour, "How long to keep snapshots, 0 keeps them forever")
+	flags.StringVar(&storeURL, "store", "", "Where to keep snapshots, e.g. file:///var/lib/hn")
+	flags.StringVar(&listen, "listen", "", "Also serve the API, with the snapshots, on an address, e.g. localhost:8080")
+	flags.IntVar(&postsToFetch, "posts", 30, "How many posts to snapshot per section. A positive integer <= 100.")
+	flags.Var(&names, "sections", "Sections to snapshot, repeatable or comma separated (default top,new,best)")
+
+	clientOptions := addClientFlags(flags)
+	clientOptions.inMemory = true
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if err := clientOptions.apply(); err != nil {
+		return err
+	}
+
+	if every <= 0 {
+		return errors.New("every must be a positive duration")
+	}
+
+	if jitter < 0 || jitter >= every {
+		return errors.New("jitter must be at least 0 and less than every")
+	}
+
+	if retention < 0 {
+		return errors.New("retention must not be negative")
+	}
+
+	if postsToFetch < 1 || postsToFetch > 100 {
+		return errors.New("posts must be between 1 and 100, inclusive")
+	}
+
+	if storeURL == "" {
+		return errors.New("usage: hn daemon -store=file:///var/lib/hn")
+	}
+
+	if len(names) == 0 {
+		names = stringList{"top", "new", "best"}
+	}
+
+	sectionsToSnapshot := make([]string, 0, len(names))
+	for _, name := range names {
+		section, err := getSection(name)
+		if err != nil {
+			return err
+		}
+		sectionsToSnapshot = append(sectionsToSnapshot, section)
+	}
+
+	s, err := openStore(storeURL)
+	if err != nil {
+		return err
+	}
+
+	// The API failing stops the daemon, the error is returned rather than exiting here
+	serveErr := make(chan error, 1)
+	if listen != "" {
+		server := &server{store: s}
+		go func() {
+			slog.Info("serving", "url", "http://"+listen)
+			serveErr <- http.ListenAndServe(listen, server.handler())
+		}()
+	}
+
+	for {
+		start := time.Now()
+
+		if jitter > 0 {
+			time.Sleep(time.Duration(rand.Int63n(int64(jitter))))
+		}
+
+		for _, section := range sectionsToSnapshot {
+			if err := snapshot(s, section, postsToFetch); err != nil {
+				// A failed snapshot is retried on the next run, rather than stopping the daemon
+				slog.Error("snapshot failed", "section", section, "error", err)
+			}
+		}
+
+		if retention > 0 {
+			pruned, err := prune(s, sectionsToSnapshot, time.Now().Add(-retention))
+			if err != nil {
+				slog.Error("pruning failed", "error", err)
+			} else if pruned > 0 {
+				slog.Info("pruned snapshots", "count", pruned, "retention", retention)
+			}
+		}
+
+		// Runs start every interval, however long the snapshots took
+		select {
+		case err := <-serveErr:
+			return err
+		case <-time.After(time.Until(start.Add(every))):
+		}
+	}
+}
+
+// prune removes the snapshots of the sections taken before a time. Only the
+// sections the daemon snapshots are pruned, the front pages of past days kept
+// by hn crawl are as old as the days they are of, and are kept whatever the
+// retention.
+func prune(s store, sections []string, before time.Time) (int, error) {
+	pruned := 0
+	for _, section := range sections {
+		n, err := s.Prune(section, before)
+		pruned += n
+		if err != nil {
+			return pruned, err
+		}
+	}
+	return pruned, nil
+}
+
+func snapshot(s store, section string, postsToFetch int) error {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	posts, err := client.FetchPosts(section, postsToFetch)
+	if err != nil {
+		return err
+	}
+
+	return s.Save(Snapshot{
+		ID:      snapshotID(section, now),
+		Section: section,
+		Time:    now,
+		Posts:   posts,
+	})
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"hn/hn"
+	"io"
+	"os"
+	"time"
+)
+
+func runDupes(args []string) error {
+	var format string
+	var timeout time.Duration
+
+	defaultFormat := "json"
+	if isTerminal(os.Stdout) {
+		defaultFormat = "human"
+	}
+
+	flags := flag.NewFlagSet("dupes", flag.ContinueOnError)
+	flags.StringVar(&format, "format", defaultFormat, "Output format, json or human (default human in a terminal, otherwise json)")
+	flags.DurationVar(&timeout, "timeout", 10*time.Second, "How long to wait for the search")
+
+	clientOptions := addClientFlags(flags)
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if err := clientOptions.apply(); err != nil {
+		return err
+	}
+
+	if format != "json" && format != "human" {
+		return fmt.Errorf("unknown format %q, must be json or human", format)
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("usage: hn dupes [-format=human] <url>")
+	}
+
+	// Past submissions are long gone from the listings, the search API of HN has them all
+	search := hn.NewSearch()
+	search.HTTPClient.Timeout = timeout
+
+	posts, err := search.Submissions(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if format == "human" {
+		return writeDupes(os.Stdout, posts)
+	}
+	return writeJSON(os.Stdout, posts)
+}
+
+// writeDupes writes when each submission was, how it did and where its discussion is
+func writeDupes(w io.Writer, posts hn.Posts) error {
+	if len(posts) == 0 {
+		_, err := fmt.Fprintln(w, "not submitted before")
+		return err
+	}
+
+	for _, post := range posts {
+		line := fmt.Sprintf("%s %5d pts %5d comments  %s\n           %s",
+			post.Time.Format("2006-01-02"), post.Points, post.Comments, post.Title, client.ItemURL(post.ID))
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"hn/hn"
+	"time"
+)
+
+// schemaVersion is the version of the JSON output, raised whenever a field is
+// renamed, removed or changes meaning. Adding fields does not raise it.
+const schemaVersion = 1
+
+// An Envelope wraps posts with what a consumer needs to trust them, written with -envelope
+type Envelope struct {
+	SchemaVersion int
+	FetchedAt     time.Time
+	Sections      []string
+	Pages         int
+	Warnings      []string
+
+	// Posts are hn.Posts, or only the fields selected with -fields
+	Posts interface{}
+}
+
+// newEnvelope wraps the posts fetched from pages pages, postsToFetch is how
+// many posts were asked for, or 0 when filters leave out an unknown number
+func newEnvelope(sections []string, pages int, postsToFetch int, fetchedAt time.Time, posts hn.Posts, fields []string) Envelope {
+	var selected interface{} = posts
+	if len(fields) > 0 {
+		selected = projectPosts(posts, fields)
+	}
+
+	return Envelope{
+		SchemaVersion: schemaVersion,
+		FetchedAt:     fetchedAt,
+		Sections:      sections,
+		Pages:         pages,
+		Warnings:      postWarnings(sections, postsToFetch, posts),
+		Posts:         selected,
+	}
+}
+
+// postWarnings points out posts that were parsed but are missing something,
+// such as the author of an advertisement, or posts that were not there at all
+func postWarnings(sections []string, postsToFetch int, posts hn.Posts) []string {
+	warnings := partialWarnings(sections, postsToFetch, posts)
+
+	for _, post := range posts {
+		if post.Points < 0 {
+			warnings = append(warnings, fmt.Sprintf("post %d is an advertisement, without author, points or comments", post.ID))
+		}
+	}
+
+	return warnings
+}
+
+// partialWarnings points out what makes the posts partial, posts that were
+// not there or could not be identified. Advertisements never have an author,
+// so they are not partial.
+func partialWarnings(sections []string, postsToFetch int, posts hn.Posts) []string {
+	warnings := make([]string, 0)
+
+	for _, post := range posts {
+		if post.ID == 0 {
+			warnings = append(warnings, fmt.Sprintf("post %q has no id", post.Title))
+		}
+	}
+
+	// Several sections are merged, so they have fewer posts than asked for in total
+	if len(sections) == 1 && postsToFetch > 0 && len(posts) < postsToFetch {
+		warnings = append(warnings, fmt.Sprintf("%s listed %d posts, %d were asked for", sections[0], len(posts), postsToFetch))
+	}
+
+	return warnings
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"hn/hn"
+	"io"
+	"net"
+)
+
+// Exit codes, for scripts to tell a network blip from HN changing its markup
+// without reading the error
+const (
+	exitOK = 0
+	// exitUsage is for mistakes in the arguments, and any other failure
+	exitUsage   = 1
+	exitNetwork = 2
+	exitParse   = 3
+	// exitPartial is for results that are missing something, with -fail-on-partial
+	exitPartial = 4
+	// exitBudget is for runs stopped by -page-budget or -request-budget
+	exitBudget = 5
+)
+
+// A partialError is returned after writing results that are missing
+// something, such as fewer posts than asked for, when asked to fail on it
+type partialError struct {
+	warnings []string
+}
+
+func (e *partialError) Error() string {
+	if len(e.warnings) == 1 {
+		return "the results are partial: " + e.warnings[0]
+	}
+	return fmt.Sprintf("the results are partial: %s, and %d more", e.warnings[0], len(e.warnings)-1)
+}
+
+// exitCode classifies an error returned by a command
+func exitCode(err error) int {
+	var partialErr *partialError
+	var netErr net.Error
+	var statusErr *hn.StatusError
+	var parseErr *hn.ParseError
+	var limitErr *hn.LimitError
+	var budgetErr *hn.BudgetError
+
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.As(err, &partialErr):
+		return exitPartial
+	case errors.As(err, &budgetErr):
+		return exitBudget
+	// A page cut short is the network, even though the parser is the one to find out
+	case errors.As(err, &netErr), errors.As(err, &statusErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return exitNetwork
+	case errors.As(err, &parseErr), errors.As(err, &limitErr):
+		return exitParse
+	}
+
+	return exitUsage
+}
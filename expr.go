@@ -0,0 +1,531 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"hn/hn"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expressions filter posts, e.g. points > 200 && comments/points > 0.5 && title matches "(?i)go".
+// Fields are those of a post, matched case insensitively, and Domain. They are
+// type checked as they are compiled, so evaluating one never fails, dividing by
+// zero is infinite as for any float.
+
+type exprType string
+
+const (
+	exprNumber exprType = "number"
+	exprString exprType = "string"
+	exprBool   exprType = "bool"
+)
+
+type expr interface {
+	typ() exprType
+	eval(post hn.Post) interface{}
+}
+
+type exprLiteral struct {
+	t     exprType
+	value interface{}
+}
+
+func (e exprLiteral) typ() exprType                 { return e.t }
+func (e exprLiteral) eval(post hn.Post) interface{} { return e.value }
+
+type exprField struct {
+	t   exprType
+	get func(post hn.Post) interface{}
+}
+
+func (e exprField) typ() exprType                 { return e.t }
+func (e exprField) eval(post hn.Post) interface{} { return e.get(post) }
+
+type exprNot struct {
+	x expr
+}
+
+func (e exprNot) typ() exprType                 { return exprBool }
+func (e exprNot) eval(post hn.Post) interface{} { return !e.x.eval(post).(bool) }
+
+type exprNeg struct {
+	x expr
+}
+
+func (e exprNeg) typ() exprType                 { return exprNumber }
+func (e exprNeg) eval(post hn.Post) interface{} { return -e.x.eval(post).(float64) }
+
+type exprArithmetic struct {
+	op   string
+	l, r expr
+}
+
+func (e exprArithmetic) typ() exprType { return exprNumber }
+
+func (e exprArithmetic) eval(post hn.Post) interface{} {
+	l, r := e.l.eval(post).(float64), e.r.eval(post).(float64)
+	switch e.op {
+	case "+":
+		return l + r
+	case "-":
+		return l - r
+	case "*":
+		return l * r
+	case "/":
+		return l / r
+	}
+	panic("unknown operator " + e.op)
+}
+
+// exprMatches matches a string to a regular expression, compiled with the expression
+type exprMatches struct {
+	x  expr
+	re *regexp.Regexp
+}
+
+func (e exprMatches) typ() exprType                 { return exprBool }
+func (e exprMatches) eval(post hn.Post) interface{} { return e.re.MatchString(e.x.eval(post).(string)) }
+
+type exprBinary struct {
+	op   string
+	t    exprType
+	l, r expr
+}
+
+func (e exprBinary) typ() exprType { return e.t }
+
+func (e exprBinary) eval(post hn.Post) interface{} {
+	// Both sides are not always evaluated, as in Go
+	switch e.op {
+	case "&&":
+		return e.l.eval(post).(bool) && e.r.eval(post).(bool)
+	case "||":
+		return e.l.eval(post).(bool) || e.r.eval(post).(bool)
+	}
+
+	l, r := e.l.eval(post), e.r.eval(post)
+	switch e.op {
+	case "==":
+		return l == r
+	case "!=":
+		return l != r
+	case "contains":
+		return strings.Contains(l.(string), r.(string))
+	}
+
+	var cmp int
+	switch l := l.(type) {
+	case float64:
+		cmp = compareNumbers(l, r.(float64))
+	case string:
+		cmp = strings.Compare(l, r.(string))
+	}
+
+	switch e.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	panic("unknown operator " + e.op)
+}
+
+func compareNumbers(l float64, r float64) int {
+	if l < r {
+		return -1
+	}
+	if l > r {
+		return 1
+	}
+	return 0
+}
+
+// exprFields are the fields of a post that can be used in expressions, by lower case name
+func exprFields() map[string]exprField {
+	fields := map[string]exprField{
+		"domain": {exprString, func(post hn.Post) interface{} { return getDomain(post.URL) }},
+	}
+
+	t := reflect.TypeOf(hn.Post{})
+	for i := 0; i < t.NumField(); i++ {
+		index := i
+		var field exprField
+		switch t.Field(i).Type.Kind() {
+		case reflect.Int:
+			field = exprField{exprNumber, func(post hn.Post) interface{} {
+				return float64(reflect.ValueOf(post).Field(index).Int())
+			}}
+		case reflect.String:
+			field = exprField{exprString, func(post hn.Post) interface{} {
+				return reflect.ValueOf(post).Field(index).String()
+			}}
+		case reflect.Bool:
+			field = exprField{exprBool, func(post hn.Post) interface{} {
+				return reflect.ValueOf(post).Field(index).Bool()
+			}}
+		default:
+			continue
+		}
+		fields[strings.ToLower(t.Field(i).Name)] = field
+	}
+
+	return fields
+}
+
+type exprToken struct {
+	kind  string // ident, number, string, op or end
+	text  string
+	value interface{}
+	col   int
+}
+
+func lexExpr(src string) ([]exprToken, error) {
+	tokens := make([]exprToken, 0)
+	runes := []rune(src)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		col := i + 1
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsLetter(r) || r == '_':
+			j := i
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, exprToken{kind: "ident", text: string(runes[i:j]), col: col})
+			i = j
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			n, err := strconv.ParseFloat(string(runes[i:j]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at column %d", string(runes[i:j]), col)
+			}
+			tokens = append(tokens, exprToken{kind: "number", text: string(runes[i:j]), value: n, col: col})
+			i = j
+		case r == '"':
+			j := i + 1
+			for j < len(runes) && runes[j] != '"' {
+				if runes[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated string at column %d", col)
+			}
+			s, err := strconv.Unquote(string(runes[i : j+1]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at column %d", col)
+			}
+			tokens = append(tokens, exprToken{kind: "string", text: string(runes[i : j+1]), value: s, col: col})
+			i = j + 1
+		default:
+			op := ""
+			for _, candidate := range []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", "+", "-", "*", "/"} {
+				if strings.HasPrefix(string(runes[i:]), candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q at column %d", r, col)
+			}
+			tokens = append(tokens, exprToken{kind: "op", text: op, col: col})
+			i += len([]rune(op))
+		}
+	}
+
+	return append(tokens, exprToken{kind: "end", col: len(runes) + 1}), nil
+}
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+	fields map[string]exprField
+}
+
+// compileExpr parses and type checks an expression, which must be a condition
+func compileExpr(src string) (expr, error) {
+	tokens, err := lexExpr(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{tokens: tokens, fields: exprFields()}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != "end" {
+		return nil, fmt.Errorf("unexpected %q at column %d", t.text, t.col)
+	}
+
+	if e.typ() != exprBool {
+		return nil, fmt.Errorf("the expression is a %s, it must be a condition such as Points > 100", e.typ())
+	}
+
+	return e, nil
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	t := p.tokens[p.pos]
+	if t.kind != "end" {
+		p.pos++
+	}
+	return t
+}
+
+func (p *exprParser) isOp(ops ...string) bool {
+	t := p.peek()
+	if t.kind != "op" {
+		return false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *exprParser) parseOr() (expr, error) {
+	return p.parseLogical("||", p.parseAnd)
+}
+
+func (p *exprParser) parseAnd() (expr, error) {
+	return p.parseLogical("&&", p.parseComparison)
+}
+
+func (p *exprParser) parseLogical(op string, operand func() (expr, error)) (expr, error) {
+	l, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOp(op) {
+		t := p.next()
+		r, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if l.typ() != exprBool || r.typ() != exprBool {
+			return nil, fmt.Errorf("%s at column %d needs conditions on both sides", op, t.col)
+		}
+		l = exprBinary{op: op, t: exprBool, l: l, r: r}
+	}
+
+	return l, nil
+}
+
+func (p *exprParser) isKeyword(keyword string) bool {
+	t := p.peek()
+	return t.kind == "ident" && strings.EqualFold(t.text, keyword)
+}
+
+func (p *exprParser) parseComparison() (expr, error) {
+	l, err := p.parseArithmetic(p.parseTerm, "+", "-")
+	if err != nil {
+		return nil, err
+	}
+
+	// The pattern is compiled with the expression, so it has to be a string
+	if p.isKeyword("matches") {
+		t := p.next()
+		pattern := p.next()
+		if l.typ() != exprString || pattern.kind != "string" {
+			return nil, fmt.Errorf("matches at column %d needs a field on the left and a quoted pattern on the right", t.col)
+		}
+		re, err := regexp.Compile(pattern.value.(string))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern at column %d: %v", pattern.col, err)
+		}
+		return exprMatches{l, re}, nil
+	}
+
+	if p.isKeyword("contains") {
+		t := p.next()
+		r, err := p.parseArithmetic(p.parseTerm, "+", "-")
+		if err != nil {
+			return nil, err
+		}
+		if l.typ() != exprString || r.typ() != exprString {
+			return nil, fmt.Errorf("contains at column %d needs strings on both sides", t.col)
+		}
+		return exprBinary{op: "contains", t: exprBool, l: l, r: r}, nil
+	}
+
+	if !p.isOp("==", "!=", "<", "<=", ">", ">=") {
+		return l, nil
+	}
+
+	t := p.next()
+	r, err := p.parseArithmetic(p.parseTerm, "+", "-")
+	if err != nil {
+		return nil, err
+	}
+
+	if l.typ() != r.typ() {
+		return nil, fmt.Errorf("%s at column %d compares a %s to a %s", t.text, t.col, l.typ(), r.typ())
+	}
+	if l.typ() == exprBool && t.text != "==" && t.text != "!=" {
+		return nil, fmt.Errorf("%s at column %d can not order conditions", t.text, t.col)
+	}
+
+	return exprBinary{op: t.text, t: exprBool, l: l, r: r}, nil
+}
+
+func (p *exprParser) parseTerm() (expr, error) {
+	return p.parseArithmetic(p.parseUnary, "*", "/")
+}
+
+// parseArithmetic parses operands joined by operators of the same precedence, left to right
+func (p *exprParser) parseArithmetic(operand func() (expr, error), ops ...string) (expr, error) {
+	l, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOp(ops...) {
+		t := p.next()
+		r, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if l.typ() != exprNumber || r.typ() != exprNumber {
+			return nil, fmt.Errorf("%s at column %d needs numbers on both sides", t.text, t.col)
+		}
+		l = exprArithmetic{op: t.text, l: l, r: r}
+	}
+
+	return l, nil
+}
+
+func (p *exprParser) parseUnary() (expr, error) {
+	if p.isOp("-") {
+		t := p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if x.typ() != exprNumber {
+			return nil, fmt.Errorf("- at column %d needs a number", t.col)
+		}
+		return exprNeg{x}, nil
+	}
+
+	if p.isOp("!") {
+		t := p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if x.typ() != exprBool {
+			return nil, fmt.Errorf("! at column %d needs a condition", t.col)
+		}
+		return exprNot{x}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (expr, error) {
+	t := p.next()
+
+	switch t.kind {
+	case "number":
+		return exprLiteral{exprNumber, t.value}, nil
+	case "string":
+		return exprLiteral{exprString, t.value}, nil
+	case "ident":
+		// Like fields, true and false are matched case insensitively
+		if strings.EqualFold(t.text, "true") {
+			return exprLiteral{exprBool, true}, nil
+		}
+		if strings.EqualFold(t.text, "false") {
+			return exprLiteral{exprBool, false}, nil
+		}
+
+		field, ok := p.fields[strings.ToLower(t.text)]
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q at column %d", t.text, t.col)
+		}
+		return field, nil
+	case "op":
+		if t.text == "(" {
+			e, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if !p.isOp(")") {
+				return nil, fmt.Errorf("missing ) at column %d", p.peek().col)
+			}
+			p.next()
+			return e, nil
+		}
+	case "end":
+		return nil, fmt.Errorf("unexpected end of expression at column %d", t.col)
+	}
+
+	return nil, fmt.Errorf("unexpected %q at column %d", t.text, t.col)
+}
+
+// addFilterFlags adds -filter, which may be repeated, and -expr, the name it first had
+func addFilterFlags(flags *flag.FlagSet) *stringList {
+	filters := &stringList{}
+	flags.Var((*filterFlag)(filters), "filter", "Only keep posts this is true for, may be repeated, e.g. 'points > 200 && comments/points > 0.5 && title matches \"(?i)go\"'")
+	flags.Var((*filterFlag)(filters), "expr", "The same as -filter")
+	return filters
+}
+
+// filterFlag is repeated rather than comma separated, as expressions have commas in them
+type filterFlag stringList
+
+func (f *filterFlag) String() string {
+	return strings.Join(*f, " && ")
+}
+
+func (f *filterFlag) Set(value string) error {
+	if _, err := compileExpr(value); err != nil {
+		return err
+	}
+	*f = append(*f, value)
+	return nil
+}
+
+// applyFilters keeps the posts every filter is true for, in whatever the client lists
+func applyFilters(filters stringList) error {
+	for _, filter := range filters {
+		e, err := compileExpr(filter)
+		if err != nil {
+			return err
+		}
+		client.Pipeline = append(client.Pipeline, exprProcessor(e))
+	}
+	return nil
+}
+
+// exprProcessor keeps the posts the expression is true for
+func exprProcessor(e expr) hn.Processor {
+	return func(post hn.Post) (hn.Post, bool) {
+		return post, e.eval(post).(bool)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"hn/hn"
+	"testing"
+)
+
+func TestExprEval(t *testing.T) {
+	post := hn.Post{ID: 1, Title: `Show HN: "Go" in 100 lines`, URL: "https://blog.example.com/go", Author: "pg", Points: 200, Comments: 50, SecondChance: true}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		// && binds tighter than ||, so the first is true || (false && false)
+		{"points > 100 || points < 100 && comments > 100", true},
+		{"(points > 100 || points < 100) && comments > 100", false},
+		{"comments > 100 && points < 100 || points > 100", true},
+		{"1 + 2 * 3 == 7", true},
+		{"(1 + 2) * 3 == 9", true},
+		{"-points < 0", true},
+		{"comments / points == 0.25", true},
+
+		// != is an operator of its own, not ! followed by =
+		{"author != \"someone\"", true},
+		{"!(author == \"someone\")", true},
+		{"!secondchance", false},
+		{"secondchance != false", true},
+
+		{"true", true},
+		{"True && !FALSE", true},
+		{"secondchance == TRUE", true},
+		{"Points > 199 && POINTS < 201", true},
+
+		{`title contains "\"Go\""`, true},
+		{`title == "Show HN: \"Go\" in 100 lines"`, true},
+		{`title contains "\t"`, false},
+		{`domain == "blog.example.com"`, true},
+
+		{`title matches "(?i)^show hn"`, true},
+		{`title MATCHES "^Ask HN"`, false},
+		{`url matches "\\.com/"`, true},
+		{`author contains "p"`, true},
+		{`author contains "x"`, false},
+		{`"abc" < "abd"`, true},
+
+		// Dividing by zero is infinite rather than an error
+		{"points / 0 > 1000000", true},
+		{"-points / 0 < 0", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			e, err := compileExpr(test.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := e.eval(post).(bool); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestExprErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"points", "the expression is a number, it must be a condition such as Points > 100"},
+		{`points > "100"`, "> at column 8 compares a number to a string"},
+		{"points > 1 && title", "&& at column 12 needs conditions on both sides"},
+		{"title + 1 > 0", "+ at column 7 needs numbers on both sides"},
+		{"!points > 1", "! at column 1 needs a condition"},
+		{`-title == ""`, "- at column 1 needs a number"},
+		{"true < false", "< at column 6 can not order conditions"},
+		{"points matches \"1\"", "matches at column 8 needs a field on the left and a quoted pattern on the right"},
+		{"title matches author", "matches at column 7 needs a field on the left and a quoted pattern on the right"},
+		{`title matches "("`, "invalid pattern at column 15: error parsing regexp: missing closing ): `(`"},
+		{"points contains 1", "contains at column 8 needs strings on both sides"},
+		{"karma > 1", `unknown field "karma" at column 1`},
+		{`title == "open`, "unterminated string at column 10"},
+		{`title == "\q"`, "invalid string at column 10"},
+		{"points > 1.2.3", `invalid number "1.2.3" at column 10`},
+		{"points > 1 & comments > 1", `unexpected '&' at column 12`},
+		{"(points > 1", "missing ) at column 12"},
+		{"points > 1)", `unexpected ")" at column 11`},
+		{"points >", "unexpected end of expression at column 9"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			_, err := compileExpr(test.expr)
+			if err == nil {
+				t.Fatalf("compiled, want error %q", test.want)
+			}
+			if err.Error() != test.want {
+				t.Errorf("got error %q, want %q", err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hn/hn"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseFields checks the names given to -fields against the fields of a post,
+// case insensitively as in GraphQL, and returns the names of the fields
+func parseFields(names []string) ([]string, error) {
+	t := reflect.TypeOf(hn.Post{})
+
+	fields := make([]string, 0, len(names))
+	for _, name := range names {
+		field, ok := t.FieldByNameFunc(func(field string) bool {
+			return strings.EqualFold(field, name)
+		})
+		if !ok {
+			known := make([]string, t.NumField())
+			for i := range known {
+				known[i] = strings.ToLower(t.Field(i).Name)
+			}
+			return nil, fmt.Errorf("unknown field %q, must be one of %s", name, strings.Join(known, ", "))
+		}
+		fields = append(fields, field.Name)
+	}
+
+	return fields, nil
+}
+
+// A projectedPost is only the selected fields of a post, kept in the order they were selected
+type projectedPost struct {
+	fields []string
+	values []interface{}
+}
+
+func (p projectedPost) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("{")
+	for i, field := range p.fields {
+		if i > 0 {
+			b.WriteString(",")
+		}
+
+		value, err := json.Marshal(p.values[i])
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&b, "%q:%s", field, value)
+	}
+	b.WriteString("}")
+
+	return b.Bytes(), nil
+}
+
+func projectPosts(posts hn.Posts, fields []string) []projectedPost {
+	projected := make([]projectedPost, len(posts))
+	for i, post := range posts {
+		v := reflect.ValueOf(post)
+
+		projected[i] = projectedPost{fields: fields, values: make([]interface{}, len(fields))}
+		for j, field := range fields {
+			projected[i].values[j] = v.FieldByName(field).Interface()
+		}
+	}
+
+	return projected
+}
+
+// fieldsFormatter writes only the selected fields. The statusbar and xbar
+// formats are laid out for the tools reading them, so they always have every field.
+func fieldsFormatter(format string, fields []string) (formatter, error) {
+	switch format {
+	case "json":
+		return func(w io.Writer, posts hn.Posts) error {
+			response, err := json.MarshalIndent(projectPosts(posts, fields), "", "    ")
+			if err != nil {
+				return err
+			}
+
+			_, err = fmt.Fprintln(w, string(response))
+			return err
+		}, nil
+	case "human":
+		return func(w io.Writer, posts hn.Posts) error {
+			return writeFieldColumns(w, projectPosts(posts, fields))
+		}, nil
+	}
+
+	return nil, fmt.Errorf("-fields is only for the json and human formats, not %s", format)
+}
+
+// writeFieldColumns writes the selected fields as aligned columns, the last one is not padded
+func writeFieldColumns(w io.Writer, posts []projectedPost) error {
+	rows := make([][]string, len(posts))
+	var widths []int
+
+	for i, post := range posts {
+		rows[i] = make([]string, len(post.fields))
+		if widths == nil {
+			widths = make([]int, len(post.fields))
+		}
+
+		for j, field := range post.fields {
+			rows[i][j] = fieldString(field, post.values[j])
+			widths[j] = max(widths[j], len(rows[i][j]))
+		}
+	}
+
+	for _, row := range rows {
+		line := ""
+		for j, value := range row {
+			if j == len(row)-1 {
+				line += value
+			} else {
+				line += fmt.Sprintf("%-*s  ", widths[j], value)
+			}
+		}
+
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func fieldString(field string, value interface{}) string {
+	switch v := value.(type) {
+	case int:
+		// Advertisements have no points or comments
+		if field == "Points" || field == "Comments" {
+			return countString(v)
+		}
+		return strconv.Itoa(v)
+	case []hn.Listing:
+		listings := make([]string, len(v))
+		for i, listing := range v {
+			listings[i] = fmt.Sprintf("%s#%d", listing.Section, listing.Rank)
+		}
+		return strings.Join(listings, ",")
+	case time.Time:
+		if v.IsZero() {
+			return "-"
+		}
+		return v.Format(time.RFC3339)
+	case *hn.Preview:
+		// Without a page to read, or when it failed, there is no title to show
+		if v == nil || v.Title == "" {
+			return "-"
+		}
+		return v.Title
+	case *hn.LinkStatus:
+		if v == nil {
+			return "-"
+		}
+		if v.Dead {
+			return "dead"
+		}
+		return strconv.Itoa(v.Status)
+	case *hn.ArchiveStatus:
+		if v == nil || v.Snapshot == "" && v.JobID == "" {
+			return "-"
+		}
+		if v.Snapshot != "" {
+			return v.Snapshot
+		}
+		return v.JobID
+	case *hn.TopComment:
+		if v == nil || v.Author == "" {
+			return "-"
+		}
+		return v.Author + ": " + v.Text
+	}
+
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hn/hn"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// A formatter writes posts to the output
+type formatter func(w io.Writer, posts hn.Posts) error
+
+func getFormatter(name string, color bool) (formatter, error) {
+	switch name {
+	case "json":
+		return writeJSON, nil
+	case "human":
+		return humanFormatter(color), nil
+	case "statusbar":
+		return writeStatusBar, nil
+	case "xbar":
+		return writeXbar, nil
+	case "opml":
+		return writeOPML, nil
+	case "netscape-bookmarks":
+		return writeBookmarks, nil
+	case "parquet":
+		return writeParquet, nil
+	}
+
+	return nil, fmt.Errorf("unknown format %q, must be json, human, statusbar, xbar, opml, netscape-bookmarks or parquet", name)
+}
+
+func writeJSON(w io.Writer, posts hn.Posts) error {
+	response, err := json.MarshalIndent(posts, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(w, string(response))
+	return err
+}
+
+// isTerminal reports whether the file is a terminal rather than a pipe or file
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+const (
+	ansiReset  = "\x1b[0m"
+	ansiDim    = "\x1b[2m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+)
+
+// humanFormatter writes posts as aligned columns, for reading in a terminal
+func humanFormatter(color bool) formatter {
+	paint := func(code string, s string) string {
+		if !color || code == "" {
+			return s
+		}
+		return code + s + ansiReset
+	}
+
+	return func(w io.Writer, posts hn.Posts) error {
+		ranks := make([]string, len(posts))
+		points := make([]string, len(posts))
+		comments := make([]string, len(posts))
+		rankWidth, pointsWidth, commentsWidth := 0, 0, 0
+
+		for i, post := range posts {
+			ranks[i] = strconv.Itoa(post.Rank) + "."
+			points[i] = countString(post.Points)
+			comments[i] = countString(post.Comments)
+
+			rankWidth = max(rankWidth, len(ranks[i]))
+			pointsWidth = max(pointsWidth, len(points[i]))
+			commentsWidth = max(commentsWidth, len(comments[i]))
+		}
+
+		for i, post := range posts {
+			pointsColor := ""
+			if post.Points > 500 {
+				pointsColor = ansiYellow
+			} else if post.Points > 100 {
+				pointsColor = ansiGreen
+			}
+
+			// Pad before painting, escape codes would otherwise count towards the width
+			line := fmt.Sprintf("%*s %s %s  %s",
+				rankWidth, ranks[i],
+				paint(pointsColor, fmt.Sprintf("%*s pts", pointsWidth, points[i])),
+				fmt.Sprintf("%*s comments", commentsWidth, comments[i]),
+				post.Title,
+			)
+
+			if domain := getDomain(post.URL); domain != "" {
+				line += " " + paint(ansiDim, "("+domain+")")
+			}
+
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// countString formats points and comments, which are -1 for advertisements
+func countString(n int) string {
+	if n < 0 {
+		return "-"
+	}
+	return strconv.Itoa(n)
+}
+
+// getDomain returns the host a post links to, or nothing for posts on the site itself
+func getDomain(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || !u.IsAbs() {
+		return ""
+	}
+
+	return strings.TrimPrefix(u.Hostname(), "www.")
+}
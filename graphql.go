@@ -0,0 +1,839 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hn/hn"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The GraphQL endpoint supports the query subset dashboards need: fields,
+// aliases, arguments and variables. Fragments, directives, mutations and
+// subscriptions are not supported.
+
+// maxGraphQLDepth caps how deeply selections and lists may nest, as queries
+// come over HTTP and each level is a call of the parser, deep enough for the
+// replies of replies of a thread
+const maxGraphQLDepth = 32
+
+type gqlField struct {
+	alias     string
+	name      string
+	arguments map[string]interface{}
+	selection []gqlField
+}
+
+func (f gqlField) key() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+// gqlVariable is a reference to a variable, resolved when the query is executed
+type gqlVariable string
+
+type gqlError struct {
+	Message string `json:"message"`
+}
+
+type gqlResponse struct {
+	Data   interface{} `json:"data"`
+	Errors []gqlError  `json:"errors,omitempty"`
+}
+
+type gqlResolver func(s *server, args gqlArguments) (interface{}, error)
+
+var gqlRoot = map[string]gqlResolver{
+	"stories":   gqlStories,
+	"item":      gqlItem,
+	"comments":  gqlComments,
+	"user":      gqlUser,
+	"snapshots": gqlSnapshots,
+	"snapshot":  gqlSnapshot,
+}
+
+// handleGraphQL accepts a query as ?query= or as a JSON body with query and variables
+func (s *server) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		request.Query = r.URL.Query().Get("query")
+		if variables := r.URL.Query().Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+				writeHTTPJSON(w, gqlResponse{Errors: []gqlError{{"invalid variables: " + err.Error()}}})
+				return
+			}
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeHTTPError(w, http.StatusBadRequest, err)
+			return
+		}
+	default:
+		writeHTTPError(w, http.StatusMethodNotAllowed, errors.New("use GET or POST"))
+		return
+	}
+
+	writeHTTPJSON(w, s.executeGraphQL(request.Query, request.Variables))
+}
+
+func (s *server) executeGraphQL(query string, variables map[string]interface{}) gqlResponse {
+	fields, defaults, err := parseGraphQL(query)
+	if err != nil {
+		return gqlResponse{Errors: []gqlError{{err.Error()}}}
+	}
+
+	for name, value := range defaults {
+		if _, ok := variables[name]; !ok {
+			if variables == nil {
+				variables = make(map[string]interface{})
+			}
+			variables[name] = value
+		}
+	}
+
+	data := make(map[string]interface{})
+	errs := make([]gqlError, 0)
+	for _, field := range fields {
+		resolve, ok := gqlRoot[field.name]
+		if !ok {
+			errs = append(errs, gqlError{fmt.Sprintf("unknown field %q on Query", field.name)})
+			data[field.key()] = nil
+			continue
+		}
+
+		// A field that fails is null, the others are still returned
+		value, err := resolve(s, gqlArguments{field.arguments, variables})
+		if err == nil {
+			// The arguments are the resolver's, stories would otherwise skip twice
+			selected := field
+			selected.arguments = nil
+			value, err = gqlSelect(reflect.ValueOf(value), selected, variables)
+		}
+
+		if err != nil {
+			errs = append(errs, gqlError{fmt.Sprintf("%s: %v", field.key(), err)})
+			value = nil
+		}
+		data[field.key()] = value
+	}
+
+	return gqlResponse{Data: data, Errors: errs}
+}
+
+// gqlSelect picks the selected fields of a value. Fields are matched to the
+// JSON field names case insensitively, so id selects ID. Lists take first and
+// skip arguments.
+func gqlSelect(v reflect.Value, field gqlField, variables map[string]interface{}) (interface{}, error) {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+
+	if !v.IsValid() {
+		return nil, nil
+	}
+
+	if isGraphQLScalar(v.Type()) {
+		if len(field.selection) > 0 {
+			return nil, fmt.Errorf("%q is a scalar and can not have a selection", field.name)
+		}
+		return v.Interface(), nil
+	}
+
+	if len(field.selection) == 0 {
+		return nil, fmt.Errorf("%q is an object and needs a selection of fields", field.name)
+	}
+
+	if v.Kind() == reflect.Slice {
+		args := gqlArguments{field.arguments, variables}
+		first, err := args.int("first", v.Len())
+		if err != nil {
+			return nil, err
+		}
+		skip, err := args.int("skip", 0)
+		if err != nil {
+			return nil, err
+		}
+
+		if first < 0 || skip < 0 {
+			return nil, errors.New("first and skip must not be negative")
+		}
+
+		list := make([]interface{}, 0)
+		for i := skip; i < v.Len() && len(list) < first; i++ {
+			value, err := gqlSelect(v.Index(i), field, variables)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		return list, nil
+	}
+
+	object := make(map[string]interface{}, len(field.selection))
+	for _, selected := range field.selection {
+		if selected.name == "__typename" {
+			object[selected.key()] = v.Type().Name()
+			continue
+		}
+
+		value := v.FieldByNameFunc(func(name string) bool {
+			return strings.EqualFold(name, selected.name)
+		})
+		if !value.IsValid() {
+			return nil, fmt.Errorf("unknown field %q on %s", selected.name, v.Type().Name())
+		}
+
+		var err error
+		object[selected.key()], err = gqlSelect(value, selected, variables)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return object, nil
+}
+
+// isGraphQLScalar reports whether a type is a leaf, anything marshalled to JSON
+// by itself or a list of them
+func isGraphQLScalar(t reflect.Type) bool {
+	if t.Implements(reflect.TypeOf((*json.Marshaler)(nil)).Elem()) {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice:
+		return isGraphQLScalar(t.Elem())
+	case reflect.Struct, reflect.Interface:
+		return false
+	}
+	return true
+}
+
+// gqlArguments are the arguments of a field, with its variables resolved
+type gqlArguments struct {
+	values    map[string]interface{}
+	variables map[string]interface{}
+}
+
+func (a gqlArguments) get(name string) (interface{}, bool) {
+	value, ok := a.values[name]
+	if variable, isVariable := value.(gqlVariable); isVariable {
+		value, ok = a.variables[string(variable)]
+	}
+	return value, ok && value != nil
+}
+
+func (a gqlArguments) string(name string, fallback string) (string, error) {
+	value, ok := a.get(name)
+	if !ok {
+		return fallback, nil
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("argument %q must be a string", name)
+	}
+	return s, nil
+}
+
+func (a gqlArguments) int(name string, fallback int) (int, error) {
+	value, ok := a.get(name)
+	if !ok {
+		return fallback, nil
+	}
+
+	// Literals are parsed as int, JSON variables as float64
+	switch n := value.(type) {
+	case int:
+		return n, nil
+	case float64:
+		if n == float64(int(n)) {
+			return int(n), nil
+		}
+	}
+	return 0, fmt.Errorf("argument %q must be an integer", name)
+}
+
+func (a gqlArguments) bool(name string, fallback bool) (bool, error) {
+	value, ok := a.get(name)
+	if !ok {
+		return fallback, nil
+	}
+
+	b, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("argument %q must be a boolean", name)
+	}
+	return b, nil
+}
+
+func (a gqlArguments) regexp(name string) (*regexp.Regexp, error) {
+	value, err := a.string(name, "")
+	if err != nil || value == "" {
+		return nil, err
+	}
+
+	re, err := regexp.Compile(value)
+	if err != nil {
+		return nil, fmt.Errorf("argument %q is not a valid regular expression: %v", name, err)
+	}
+	return re, nil
+}
+
+// gqlStories lists a section, filtered by minPoints, author and titleMatches
+func gqlStories(s *server, args gqlArguments) (interface{}, error) {
+	name, err := args.string("section", "top")
+	if err != nil {
+		return nil, err
+	}
+
+	section, err := getSection(name)
+	if err != nil {
+		return nil, err
+	}
+
+	minPoints, err := args.int("minPoints", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	author, err := args.string("author", "")
+	if err != nil {
+		return nil, err
+	}
+
+	titleMatches, err := args.regexp("titleMatches")
+	if err != nil {
+		return nil, err
+	}
+
+	first, err := args.int("first", 30)
+	if err != nil {
+		return nil, err
+	}
+
+	skip, err := args.int("skip", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if first < 0 || skip < 0 {
+		return nil, errors.New("first and skip must not be negative")
+	}
+
+	// Filters can only be applied to every post there is, up to the first 100.
+	// skip+first is not compared as it can overflow.
+	postsToFetch := 100
+	if minPoints == 0 && author == "" && titleMatches == nil && first < postsToFetch-skip {
+		postsToFetch = skip + first
+	}
+
+	if postsToFetch == 0 {
+		return hn.Posts{}, nil
+	}
+
+	posts, err := client.FetchPosts(section, postsToFetch)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make(hn.Posts, 0)
+	for _, post := range posts {
+		if post.Points < minPoints || author != "" && post.Author != author {
+			continue
+		}
+		if titleMatches != nil && !titleMatches.MatchString(post.Title) {
+			continue
+		}
+		matches = append(matches, post)
+	}
+
+	if skip > len(matches) {
+		skip = len(matches)
+	}
+	matches = matches[skip:]
+	if first < len(matches) {
+		matches = matches[:first]
+	}
+
+	return matches, nil
+}
+
+func gqlItem(s *server, args gqlArguments) (interface{}, error) {
+	id, err := gqlItemID(args)
+	if err != nil {
+		return nil, err
+	}
+
+	textFormat, err := args.string("textFormat", hn.TextMarkdown)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := hn.ValidTextFormat(textFormat); err != nil {
+		return nil, err
+	}
+
+	item, err := client.FetchItem(id)
+	if err != nil {
+		return nil, err
+	}
+	hn.FormatItemText(item, textFormat)
+
+	return item, nil
+}
+
+// gqlComments selects the comments of an item like hn comments does
+func gqlComments(s *server, args gqlArguments) (interface{}, error) {
+	id, err := gqlItemID(args)
+	if err != nil {
+		return nil, err
+	}
+
+	var options commentOptions
+	if options.match, err = args.regexp("match"); err != nil {
+		return nil, err
+	}
+	if options.top, err = args.int("top", 0); err != nil {
+		return nil, err
+	}
+	if options.maxDepth, err = args.int("maxDepth", 0); err != nil {
+		return nil, err
+	}
+	if options.flat, err = args.bool("flat", false); err != nil {
+		return nil, err
+	}
+	if options.textFormat, err = args.string("textFormat", hn.TextMarkdown); err != nil {
+		return nil, err
+	}
+
+	if err := hn.ValidTextFormat(options.textFormat); err != nil {
+		return nil, err
+	}
+
+	item, err := client.FetchItem(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return selectComments(item.Replies, options), nil
+}
+
+// gqlItemID accepts an id as an integer, or an id or url as a string
+func gqlItemID(args gqlArguments) (int, error) {
+	if id, err := args.int("id", 0); err == nil && id != 0 {
+		return id, nil
+	}
+
+	value, err := args.string("id", "")
+	if err != nil || value == "" {
+		return -1, errors.New("argument \"id\" must be an item id or url")
+	}
+
+	return hn.ParseItemID(value)
+}
+
+// gqlUser fetches the profile of a user by name
+func gqlUser(s *server, args gqlArguments) (interface{}, error) {
+	name, err := args.string("name", "")
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		return nil, errors.New("argument \"name\" must be a user name")
+	}
+
+	return client.FetchUser(name)
+}
+
+func gqlSnapshots(s *server, args gqlArguments) (interface{}, error) {
+	if s.store == nil {
+		return nil, errors.New("no store is configured")
+	}
+
+	section := ""
+	name, err := args.string("section", "")
+	if err != nil {
+		return nil, err
+	}
+
+	if name != "" {
+		section, err = getSection(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s.store.List(section)
+}
+
+func gqlSnapshot(s *server, args gqlArguments) (interface{}, error) {
+	if s.store == nil {
+		return nil, errors.New("no store is configured")
+	}
+
+	id, err := args.string("id", "")
+	if err != nil {
+		return nil, err
+	}
+
+	return s.store.Load(id)
+}
+
+// gqlParser is a recursive descent parser over the query, a token at a time
+type gqlParser struct {
+	query string
+	pos   int
+	token string
+
+	// depth is how many selections and lists the parser is in
+	depth int
+}
+
+// parseGraphQL parses a single query operation, returning its fields and the defaults of its variables
+func parseGraphQL(query string) ([]gqlField, map[string]interface{}, error) {
+	p := &gqlParser{query: query}
+	if err := p.next(); err != nil {
+		return nil, nil, err
+	}
+
+	defaults := make(map[string]interface{})
+	switch p.token {
+	case "{":
+	case "query":
+		if err := p.next(); err != nil {
+			return nil, nil, err
+		}
+
+		if isGraphQLName(p.token) {
+			if err := p.next(); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		if p.token == "(" {
+			if err := p.parseVariableDefinitions(defaults); err != nil {
+				return nil, nil, err
+			}
+		}
+	case "mutation", "subscription", "fragment":
+		return nil, nil, fmt.Errorf("%s is not supported, only queries are", p.token)
+	default:
+		return nil, nil, p.errorf("expected a query")
+	}
+
+	fields, err := p.parseSelectionSet()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if p.token != "" {
+		return nil, nil, p.errorf("expected the end of the query, only one operation is supported")
+	}
+
+	return fields, defaults, nil
+}
+
+func (p *gqlParser) errorf(format string, args ...interface{}) error {
+	found := p.token
+	if found == "" {
+		found = "the end of the query"
+	}
+	return fmt.Errorf("syntax error at offset %d, near %q: %s", p.pos, found, fmt.Sprintf(format, args...))
+}
+
+// enter goes into a selection or list, which may only nest up to maxGraphQLDepth
+func (p *gqlParser) enter() error {
+	p.depth++
+	if p.depth > maxGraphQLDepth {
+		return p.errorf("selections and lists may only nest %d deep", maxGraphQLDepth)
+	}
+	return nil
+}
+
+// next reads the next token, skipping whitespace, commas and comments
+func (p *gqlParser) next() error {
+	for p.pos < len(p.query) {
+		c := p.query[p.pos]
+		if c == '#' {
+			for p.pos < len(p.query) && p.query[p.pos] != '\n' {
+				p.pos++
+			}
+		} else if c == ',' || c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			p.pos++
+		} else {
+			break
+		}
+	}
+
+	if p.pos >= len(p.query) {
+		p.token = ""
+		return nil
+	}
+
+	start := p.pos
+	c := p.query[p.pos]
+	switch {
+	case strings.IndexByte("{}():$![]=@", c) >= 0:
+		p.pos++
+	case c == '.':
+		if !strings.HasPrefix(p.query[p.pos:], "...") {
+			return p.errorf("unexpected character %q", c)
+		}
+		return fmt.Errorf("fragments are not supported")
+	case c == '"':
+		p.pos++
+		for p.pos < len(p.query) && p.query[p.pos] != '"' {
+			if p.query[p.pos] == '\\' {
+				p.pos++
+			}
+			p.pos++
+		}
+		if p.pos >= len(p.query) {
+			return p.errorf("unterminated string")
+		}
+		p.pos++
+	case c == '-' || c >= '0' && c <= '9':
+		p.pos++
+		for p.pos < len(p.query) && strings.IndexByte("0123456789.eE+-", p.query[p.pos]) >= 0 {
+			p.pos++
+		}
+	case c == '_' || unicode.IsLetter(rune(c)):
+		for p.pos < len(p.query) && (p.query[p.pos] == '_' || unicode.IsLetter(rune(p.query[p.pos])) || unicode.IsDigit(rune(p.query[p.pos]))) {
+			p.pos++
+		}
+	default:
+		return p.errorf("unexpected character %q", c)
+	}
+
+	p.token = p.query[start:p.pos]
+	return nil
+}
+
+func (p *gqlParser) expect(token string) error {
+	if p.token != token {
+		return p.errorf("expected %q", token)
+	}
+	return p.next()
+}
+
+func isGraphQLName(token string) bool {
+	return token != "" && (token[0] == '_' || unicode.IsLetter(rune(token[0])))
+}
+
+// parseVariableDefinitions reads ($name: Type = default, ...), only the defaults are kept
+func (p *gqlParser) parseVariableDefinitions(defaults map[string]interface{}) error {
+	if err := p.expect("("); err != nil {
+		return err
+	}
+
+	for p.token != ")" {
+		if err := p.expect("$"); err != nil {
+			return err
+		}
+
+		name := p.token
+		if !isGraphQLName(name) {
+			return p.errorf("expected a variable name")
+		}
+		if err := p.next(); err != nil {
+			return err
+		}
+
+		if err := p.expect(":"); err != nil {
+			return err
+		}
+
+		// Types are not checked, arguments are checked as they are read
+		for p.token == "[" || p.token == "]" || p.token == "!" || isGraphQLName(p.token) {
+			if err := p.next(); err != nil {
+				return err
+			}
+		}
+
+		if p.token == "=" {
+			if err := p.next(); err != nil {
+				return err
+			}
+
+			value, err := p.parseValue()
+			if err != nil {
+				return err
+			}
+			defaults[name] = value
+		}
+	}
+
+	return p.expect(")")
+}
+
+func (p *gqlParser) parseSelectionSet() ([]gqlField, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+
+	fields := make([]gqlField, 0)
+	for p.token != "}" {
+		if p.token == "@" {
+			return nil, errors.New("directives are not supported")
+		}
+
+		if !isGraphQLName(p.token) {
+			return nil, p.errorf("expected a field")
+		}
+
+		field := gqlField{name: p.token}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+
+		if p.token == ":" {
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+
+			if !isGraphQLName(p.token) {
+				return nil, p.errorf("expected a field after the alias %q", field.name)
+			}
+
+			field.alias, field.name = field.name, p.token
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+
+		if p.token == "(" {
+			var err error
+			field.arguments, err = p.parseArguments()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if p.token == "{" {
+			var err error
+			field.selection, err = p.parseSelectionSet()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields, p.expect("}")
+}
+
+func (p *gqlParser) parseArguments() (map[string]interface{}, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	arguments := make(map[string]interface{})
+	for p.token != ")" {
+		name := p.token
+		if !isGraphQLName(name) {
+			return nil, p.errorf("expected an argument")
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		arguments[name] = value
+	}
+
+	return arguments, p.expect(")")
+}
+
+func (p *gqlParser) parseValue() (interface{}, error) {
+	token := p.token
+	var value interface{}
+
+	switch {
+	case token == "$":
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if !isGraphQLName(p.token) {
+			return nil, p.errorf("expected a variable name")
+		}
+		value = gqlVariable(p.token)
+	case token == "[":
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer func() { p.depth-- }()
+
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+
+		list := make([]interface{}, 0)
+		for p.token != "]" {
+			if p.token == "" {
+				return nil, p.errorf("unterminated list")
+			}
+
+			item, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		value = list
+	case strings.HasPrefix(token, `"`):
+		s, err := strconv.Unquote(token)
+		if err != nil {
+			return nil, p.errorf("invalid string")
+		}
+		value = s
+	case token == "true" || token == "false":
+		value = token == "true"
+	case token == "null":
+		value = nil
+	case isGraphQLName(token):
+		// Enum values, such as sections, are read as strings
+		value = token
+	case token != "" && (token[0] == '-' || token[0] >= '0' && token[0] <= '9'):
+		if n, err := strconv.Atoi(token); err == nil {
+			value = n
+		} else if f, err := strconv.ParseFloat(token, 64); err == nil {
+			value = f
+		} else {
+			return nil, p.errorf("invalid number")
+		}
+	default:
+		return nil, p.errorf("expected a value")
+	}
+
+	return value, p.next()
+}
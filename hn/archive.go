@@ -0,0 +1,143 @@
+package hn
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// An ArchiveStatus is how saving the page of a story to the Wayback Machine
+// went. Saves with keys are queued, JobID is the job to look up the snapshot by.
+type ArchiveStatus struct {
+	Snapshot string `json:",omitempty"`
+	JobID    string `json:",omitempty"`
+	Error    string `json:",omitempty"`
+}
+
+// An Archiver saves pages with the Save Page Now API of the Internet Archive,
+// which limits how often pages are saved, more so without keys
+type Archiver struct {
+	HTTPClient *http.Client
+
+	// SaveURL is the Save Page Now endpoint
+	SaveURL string
+
+	// AccessKey and Secret are the S3 keys of an archive.org account, which
+	// queue saves with the SPN2 API rather than saving them anonymously
+	AccessKey string
+	Secret    string
+
+	// Limiter, if set, limits the rate of saves
+	Limiter *RateLimiter
+}
+
+// NewArchiver saves anonymously, waiting up to 2 minutes for a save as the
+// archive fetches the page while the request waits
+func NewArchiver() *Archiver {
+	return &Archiver{
+		HTTPClient: &http.Client{Timeout: 2 * time.Minute},
+		SaveURL:    "https://web.archive.org/save",
+	}
+}
+
+// Archive saves the page of every story that links to another site, one at a
+// time, and sets its Archive. A save that fails sets the Error of its status.
+func (a *Archiver) Archive(posts Posts) {
+	for i := range posts {
+		u, err := url.Parse(posts[i].URL)
+		if err != nil || !u.IsAbs() || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+
+		status, err := a.Save(u.String())
+		if err != nil {
+			status = ArchiveStatus{Error: err.Error()}
+		}
+		posts[i].Archive = &status
+	}
+}
+
+// Save asks the Wayback Machine to save a page
+func (a *Archiver) Save(u string) (ArchiveStatus, error) {
+	if a.Limiter != nil {
+		a.Limiter.Wait()
+	}
+
+	if a.AccessKey != "" {
+		return a.queue(u)
+	}
+
+	// Anonymous saves answer with the snapshot, or redirect to it
+	resp, err := a.HTTPClient.Get(strings.TrimSuffix(a.SaveURL, "/") + "/" + u)
+	if err != nil {
+		return ArchiveStatus{}, err
+	}
+	defer resp.Body.Close()
+
+	if err := saveError(resp); err != nil {
+		return ArchiveStatus{}, err
+	}
+
+	if location := resp.Header.Get("Content-Location"); location != "" {
+		snapshot, err := resp.Request.URL.Parse(location)
+		if err != nil {
+			return ArchiveStatus{}, err
+		}
+		return ArchiveStatus{Snapshot: snapshot.String()}, nil
+	}
+	if strings.Contains(resp.Request.URL.Path, "/web/") {
+		return ArchiveStatus{Snapshot: resp.Request.URL.String()}, nil
+	}
+
+	return ArchiveStatus{}, fmt.Errorf("saving %s gave no snapshot", u)
+}
+
+// queue submits a save to the SPN2 API, which answers with a job rather than waiting for the snapshot
+func (a *Archiver) queue(u string) (ArchiveStatus, error) {
+	req, err := http.NewRequest(http.MethodPost, a.SaveURL, strings.NewReader(url.Values{"url": {u}}.Encode()))
+	if err != nil {
+		return ArchiveStatus{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "LOW "+a.AccessKey+":"+a.Secret)
+
+	resp, err := a.HTTPClient.Do(req)
+	if err != nil {
+		return ArchiveStatus{}, err
+	}
+	defer resp.Body.Close()
+
+	if err := saveError(resp); err != nil {
+		return ArchiveStatus{}, err
+	}
+
+	var job struct {
+		JobID   string `json:"job_id"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&job); err != nil {
+		return ArchiveStatus{}, fmt.Errorf("reading the save of %s: %v", u, err)
+	}
+	if job.JobID == "" {
+		return ArchiveStatus{}, fmt.Errorf("saving %s failed: %s", u, job.Message)
+	}
+
+	return ArchiveStatus{JobID: job.JobID}, nil
+}
+
+func saveError(resp *http.Response) error {
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("the Wayback Machine limits saves, lower the archive rate")
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("the Wayback Machine refused the save with %s", resp.Status)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return fmt.Errorf("saving failed with %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package hn
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Cache keeps the pages a client fetched, by url, for as long as they are fresh
+type Cache interface {
+	// Get returns a page and when it was fetched, if it is cached and still fresh
+	Get(url string) ([]byte, time.Time, bool)
+	// Put caches a page fetched at a time, replacing the one of the same url
+	Put(url string, page []byte, fetched time.Time)
+	// Invalidate removes the pages whose url starts with prefix
+	Invalidate(prefix string)
+}
+
+// WithCache keeps pages in a cache, which every fetch reads from first
+func WithCache(cache Cache) Option {
+	return func(c *Client) {
+		c.Cache = cache
+	}
+}
+
+// WithRefresh fetches every page anew, though it is cached, and caches it again
+func WithRefresh() Option {
+	return func(c *Client) {
+		c.Refresh = true
+	}
+}
+
+// A MemoryCache keeps at most Size pages in memory for TTL, dropping the
+// least recently used first. It suits long running servers.
+type MemoryCache struct {
+	Size int
+	TTL  time.Duration
+
+	mutex   sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type memoryEntry struct {
+	url     string
+	page    []byte
+	fetched time.Time
+}
+
+// NewMemoryCache keeps up to size pages for ttl
+func NewMemoryCache(size int, ttl time.Duration) *MemoryCache {
+	return &MemoryCache{Size: size, TTL: ttl, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (m *MemoryCache) Get(url string) ([]byte, time.Time, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	element, ok := m.entries[url]
+	if !ok {
+		return nil, time.Time{}, false
+	}
+
+	entry := element.Value.(*memoryEntry)
+	if time.Since(entry.fetched) > m.TTL {
+		m.order.Remove(element)
+		delete(m.entries, url)
+		return nil, time.Time{}, false
+	}
+
+	m.order.MoveToFront(element)
+	return entry.page, entry.fetched, true
+}
+
+func (m *MemoryCache) Put(url string, page []byte, fetched time.Time) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if element, ok := m.entries[url]; ok {
+		m.order.Remove(element)
+	}
+	m.entries[url] = m.order.PushFront(&memoryEntry{url: url, page: page, fetched: fetched})
+
+	for m.Size > 0 && m.order.Len() > m.Size {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoryEntry).url)
+	}
+}
+
+func (m *MemoryCache) Invalidate(prefix string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for url, element := range m.entries {
+		if strings.HasPrefix(url, prefix) {
+			m.order.Remove(element)
+			delete(m.entries, url)
+		}
+	}
+}
+
+// Tiers are caches checked in order, such as memory before disk. A page found
+// in a later tier is put in the earlier ones, pages are put in all of them.
+type Tiers []Cache
+
+func (t Tiers) Get(url string) ([]byte, time.Time, bool) {
+	for i, cache := range t {
+		if page, fetched, ok := cache.Get(url); ok {
+			for _, earlier := range t[:i] {
+				earlier.Put(url, page, fetched)
+			}
+			return page, fetched, true
+		}
+	}
+	return nil, time.Time{}, false
+}
+
+func (t Tiers) Put(url string, page []byte, fetched time.Time) {
+	for _, cache := range t {
+		cache.Put(url, page, fetched)
+	}
+}
+
+func (t Tiers) Invalidate(prefix string) {
+	for _, cache := range t {
+		cache.Invalidate(prefix)
+	}
+}
@@ -0,0 +1,607 @@
+// Package hn fetches and parses the pages of Hacker News. It has no dependencies
+// on the operating system, so it also builds for GOOS=js GOARCH=wasm.
+package hn
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"golang.org/x/net/html"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BaseURL is the site the default client fetches from
+const BaseURL = "https://news.ycombinator.com/"
+
+// Sections of the site that list posts
+const (
+	SectionTop  = "news"
+	SectionNew  = "newest"
+	SectionBest = "best"
+	SectionAsk  = "ask"
+	SectionShow = "show"
+	SectionJobs = "jobs"
+
+	// SectionFront is the front page of a past day, see FetchDay
+	SectionFront = "front"
+)
+
+// PostsPerPage is how many posts a page of a section lists
+const PostsPerPage = 30
+
+// A Client fetches and parses pages from the site
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+
+	// Limiter, if set, limits the rate of every request the client makes
+	Limiter *RateLimiter
+
+	// Limits cap the size of the pages the client reads
+	Limits Limits
+
+	// Session, if set, makes requests as a logged in user, see Login
+	Session string
+
+	// Politeness delays requests and caps how many the client sends
+	Politeness Politeness
+
+	// Logger, if set, gets debug logs of every page, how long it took and how
+	// each post was parsed, to report markup changes with
+	Logger *slog.Logger
+
+	// OnParseError, if set, is called with the page that failed to parse
+	OnParseError func(u string, page []byte, err error)
+
+	// Pipeline processes the posts of every listing fetched, see WithProcessors
+	Pipeline Pipeline
+
+	// Streaming parses listings and items a token at a time rather than as a
+	// whole, see WithStreaming
+	Streaming bool
+
+	// Profile is the markup of the site, DefaultProfile if nil, see WithProfile
+	Profile *Profile
+
+	// Cache, if set, keeps the pages fetched, which are read from it while they
+	// are fresh. Refresh fetches them anew all the same, and caches them again.
+	Cache   Cache
+	Refresh bool
+
+	spent *spending
+}
+
+// DefaultClient fetches from news.ycombinator.com with the default HTTP client
+var DefaultClient = NewClient()
+
+// An Option configures a client
+type Option func(c *Client)
+
+// NewClient returns a client for news.ycombinator.com, unless configured otherwise, e.g.
+//
+//	client := hn.NewClient(hn.WithHTTPClient(server.Client()), hn.WithBaseURL(server.URL))
+func NewClient(options ...Option) *Client {
+	c := &Client{BaseURL: BaseURL, HTTPClient: http.DefaultClient, Limits: DefaultLimits, spent: &spending{}}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// WithHTTPClient makes requests with the HTTP client, which allows adding
+// instrumentation, retries or caching to its transport
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.HTTPClient = httpClient
+	}
+}
+
+// WithBaseURL fetches from another site, such as a mirror or a test server
+func WithBaseURL(u string) Option {
+	return func(c *Client) {
+		if !strings.HasSuffix(u, "/") {
+			u += "/"
+		}
+		c.BaseURL = u
+	}
+}
+
+// WithRateLimiter limits the rate of every request the client makes
+func WithRateLimiter(limiter *RateLimiter) Option {
+	return func(c *Client) {
+		c.Limiter = limiter
+	}
+}
+
+// WithLimits caps the size of the pages the client reads, instead of DefaultLimits
+func WithLimits(limits Limits) Option {
+	return func(c *Client) {
+		c.Limits = limits
+	}
+}
+
+// WithLogger sends debug logs of every page and post parsed to the logger
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) {
+		c.Logger = logger
+	}
+}
+
+// WithStreaming parses listings and items a token at a time, only holding a
+// row of the page at a time, which takes far less memory on items with
+// thousands of comments
+func WithStreaming() Option {
+	return func(c *Client) {
+		c.Streaming = true
+	}
+}
+
+// WithParseErrorHandler calls handle with every page that fails to parse, e.g. to save it
+func WithParseErrorHandler(handle func(u string, page []byte, err error)) Option {
+	return func(c *Client) {
+		c.OnParseError = handle
+	}
+}
+
+// discardLogger logs nothing, for clients without a logger
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))
+
+func (c *Client) logger() *slog.Logger {
+	if c.Logger == nil {
+		return discardLogger
+	}
+	return c.Logger
+}
+
+// FetchPosts fetches enough pages of a section in parallel to return the first postsToFetch posts
+func (c *Client) FetchPosts(section string, postsToFetch int) (Posts, error) {
+	u := c.BaseURL + section
+
+	pagesToFetch := math.Ceil(float64(postsToFetch) / float64(PostsPerPage))
+	c.cacheListing(u, int(pagesToFetch))
+
+	// Buffered so the pages still being fetched can finish after the first error
+	resultChan := make(chan result, int(pagesToFetch))
+	errorChan := make(chan error, int(pagesToFetch))
+
+	for page := 1.0; page <= pagesToFetch; page += 1.0 {
+		go c.fetch(u, int(page), resultChan, errorChan)
+	}
+
+	// Pages are kept apart until all are in, as they come in any order and may list fewer posts than a page holds
+	pages := make([]Posts, int(pagesToFetch))
+	pagesFetched := 0
+	for pagesFetched < len(pages) {
+		select {
+		case result := <-resultChan:
+			pages[result.page-1] = result.posts
+			pagesFetched++
+		case err := <-errorChan:
+			return nil, err
+		}
+	}
+
+	posts := make(Posts, 0, postsToFetch)
+	for i, page := range pages {
+		if len(page) < PostsPerPage {
+			c.logger().Debug("page listed fewer posts than a page holds", "section", section, "page", i+1, "posts", len(page))
+		}
+		posts = append(posts, page...)
+	}
+	if len(posts) > postsToFetch {
+		posts = posts[:postsToFetch]
+	} else if len(posts) < postsToFetch {
+		c.logger().Debug("section listed fewer posts than asked for", "section", section, "posts", len(posts), "asked", postsToFetch)
+	}
+
+	// Order is only guaranteed once duplicates are gone, by rank and then id
+	posts = posts.Dedupe()
+	posts.Sort()
+
+	// The front page is ranked by age as much as votes, so a post too old for its rank was re-upped
+	now := time.Now()
+	for i := range posts {
+		posts[i].Section = section
+		posts[i].SecondChance = section == SectionTop && IsSecondChance(posts[i], now)
+	}
+
+	return c.Pipeline.Process(posts), nil
+}
+
+// FetchSections fetches the first postsToFetch posts of several sections in
+// parallel, merged by MergeSections
+func (c *Client) FetchSections(sections []string, postsToFetch int) (Posts, error) {
+	lists := make([]Posts, len(sections))
+	errs := make([]error, len(sections))
+
+	var wait sync.WaitGroup
+	for i, section := range sections {
+		wait.Add(1)
+		go func(i int, section string) {
+			defer wait.Done()
+			lists[i], errs[i] = c.FetchPosts(section, postsToFetch)
+		}(i, section)
+	}
+	wait.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return MergeSections(sections, lists), nil
+}
+
+// FetchUntil follows the More link of a section from its first page, a page at
+// a time, and returns the posts before the first one stop is true for, and how
+// many pages it fetched. It fetches at most maxPages pages, newest continues from
+// the last post of a page rather than by page number, so no post is missed.
+func (c *Client) FetchUntil(section string, maxPages int, stop func(post Post) bool) (Posts, int, error) {
+	base, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	posts := make(Posts, 0)
+	next := c.BaseURL + section
+	pages := 0
+
+	for next != "" && pages < maxPages {
+		page, more, err := c.fetchPosts(next, pages == 0)
+		if err != nil {
+			return nil, pages, err
+		}
+		pages++
+
+		for _, post := range page {
+			post.Section = section
+			if stop(post) {
+				return c.Pipeline.Process(posts.Dedupe()), pages, nil
+			}
+			posts = append(posts, post)
+		}
+
+		next = ""
+		if more != "" {
+			u, err := base.Parse(more)
+			if err != nil {
+				return nil, pages, err
+			}
+			next = u.String()
+		}
+	}
+
+	return c.Pipeline.Process(posts.Dedupe()), pages, nil
+}
+
+// FetchDay fetches the first postsToFetch posts of the front page of a past
+// day, as HN ranks the stories that were on it
+func (c *Client) FetchDay(day time.Time, postsToFetch int) (Posts, error) {
+	section := SectionFront + "?day=" + day.Format("2006-01-02")
+	pages := int(math.Ceil(float64(postsToFetch) / float64(PostsPerPage)))
+
+	fetched := 0
+	posts, _, err := c.FetchUntil(section, pages, func(post Post) bool {
+		fetched++
+		return fetched > postsToFetch
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range posts {
+		posts[i].Section = SectionFront
+	}
+	return posts, nil
+}
+
+// FetchItem fetches a story with its text and comments
+func (c *Client) FetchItem(id int) (*Item, error) {
+	u := c.ItemURL(id)
+
+	var item *Item
+	var err error
+	if c.Streaming {
+		err = c.fetchBody(u, func(body io.Reader) (err error) {
+			item, err = c.profile().scanItem(newPageScanner(body, u, c.Limits.Nodes), id)
+			return err
+		})
+	} else {
+		err = c.fetchPage(u, func(node *html.Node) (err error) {
+			item, err = c.profile().getItem(node, id)
+			return err
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if max := c.Limits.CommentDepth; max > 0 && commentDepth(item.Replies) > max {
+		return nil, &LimitError{URL: u, Limit: "comment depth", Max: int64(max)}
+	}
+	c.Limits.cutPost(&item.Post)
+
+	return item, nil
+}
+
+// ItemURL is the page of an item, with its comments
+func (c *Client) ItemURL(id int) string {
+	return c.BaseURL + "item?id=" + strconv.Itoa(id)
+}
+
+// StoryURL is where a post links to, text posts such as Ask HN link relative to the site
+func (c *Client) StoryURL(post Post) (string, error) {
+	base, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := base.Parse(post.URL)
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
+
+func (c *Client) fetch(url string, page int, results chan result, errors chan error) {
+	// TODO: Consider sending Accept, Language and User-Agent headers
+	// TODO: Ideally we should a url builder here to ensure valid urls are generated
+	u := url + "?p=" + strconv.Itoa(page)
+
+	posts, _, err := c.fetchPosts(u, page == 1)
+	if err != nil {
+		errors <- err
+		return
+	}
+
+	results <- result{
+		page:  page,
+		posts: posts,
+	}
+}
+
+// cutPosts cuts titles and authors to the text length limit, after parsing so
+// that a character is never cut in two
+func (c *Client) cutPosts(posts Posts) {
+	for i := range posts {
+		c.Limits.cutPost(&posts[i])
+	}
+}
+
+type result struct {
+	page  int
+	posts Posts
+}
+
+// fetchPosts fetches a page of a listing, and the link to its next page. The
+// pages after the last list no posts, but the first page of a listing always
+// lists some, so one without any failed to parse.
+func (c *Client) fetchPosts(u string, first bool) (posts Posts, more string, err error) {
+	logger := c.logger().With("url", u)
+
+	if c.Streaming {
+		err = c.fetchBody(u, func(body io.Reader) (err error) {
+			posts, more, err = c.profile().scanPosts(newPageScanner(body, u, c.Limits.Nodes), logger)
+			if err == nil && first && len(posts) == 0 {
+				err = c.profile().errNoPosts()
+			}
+			return err
+		})
+		c.cutPosts(posts)
+		return posts, more, err
+	}
+
+	err = c.fetchPage(u, func(node *html.Node) (err error) {
+		posts, err = c.profile().getPosts(node, logger)
+		more = c.profile().getMoreURL(node)
+		if err == nil && first && len(posts) == 0 {
+			err = c.profile().errNoPosts()
+		}
+		return err
+	})
+	c.cutPosts(posts)
+	return posts, more, err
+}
+
+// fetchPage fetches a page and parses it with parse, logging how long each
+// took. A page that fails to parse is passed to OnParseError, if it is set.
+func (c *Client) fetchPage(u string, parse func(node *html.Node) error) error {
+	return c.fetchBody(u, func(body io.Reader) error {
+		node, err := c.parsePage(u, body)
+		if err != nil {
+			return err
+		}
+		return parse(node)
+	})
+}
+
+// fetchBody fetches a page and reads it with read, within the response size
+// limit, for parsers that do not need the whole page as a tree
+func (c *Client) fetchBody(u string, read func(body io.Reader) error) error {
+	cache := c.cache()
+	if cache != nil && !c.Refresh {
+		if page, fetched, ok := cache.Get(u); ok {
+			c.logger().Debug("page cached", "url", u, "age", time.Since(fetched).Round(time.Second))
+			if err := read(bytes.NewReader(page)); err != nil {
+				return c.parseFailed(u, page, err)
+			}
+			return nil
+		}
+	}
+
+	if err := c.spendPage(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	resp, err := c.do(c.HTTPClient, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{URL: u, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	// Keep the page as it was read, to report a parse failure with or to cache
+	var body io.Reader = resp.Body
+	var raw *bytes.Buffer
+	if c.OnParseError != nil || cache != nil {
+		raw = &bytes.Buffer{}
+		body = io.TeeReader(body, raw)
+	}
+
+	responded := time.Now()
+	body, err = c.limitBody(u, body, resp.ContentLength)
+	if err == nil {
+		err = read(body)
+	}
+	if err == nil && cache != nil {
+		// Streaming parsers may stop before the end of the page, which is cached whole
+		if _, err = io.Copy(io.Discard, body); err == nil {
+			cache.Put(u, raw.Bytes(), start)
+		}
+	}
+
+	c.logger().Debug("page", "url", u, "status", resp.StatusCode, "response", responded.Sub(start), "parse", time.Since(responded))
+
+	if err != nil {
+		var page []byte
+		if raw != nil {
+			page = raw.Bytes()
+		}
+		return c.parseFailed(u, page, err)
+	}
+
+	return nil
+}
+
+// parseFailed reports a page that failed to parse, whether fetched or cached,
+// and returns the error as a ParseError, unless it is a limit that was hit
+func (c *Client) parseFailed(u string, page []byte, err error) error {
+	c.logger().Debug("page failed to parse", "url", u, "error", err)
+	if c.OnParseError != nil {
+		c.OnParseError(u, page, err)
+	}
+
+	var limitErr *LimitError
+	if errors.As(err, &limitErr) {
+		return err
+	}
+	return &ParseError{URL: u, Err: err}
+}
+
+// cache is the cache of the client, if pages may be cached. Pages of a logged
+// in user have the forms of that user, and their one time tokens, so they never are.
+func (c *Client) cache() Cache {
+	if c.Session != "" {
+		return nil
+	}
+	return c.Cache
+}
+
+// cacheListing makes sure the pages of a listing are either all read from the
+// cache or all fetched, a listing put together from pages fetched at
+// different times would have posts twice, or not at all, as they move between them
+func (c *Client) cacheListing(u string, pages int) {
+	cache := c.cache()
+	if cache == nil || c.Refresh {
+		return
+	}
+
+	for page := 1; page <= pages; page++ {
+		if _, _, ok := cache.Get(u + "?p=" + strconv.Itoa(page)); !ok {
+			cache.Invalidate(u + "?")
+			return
+		}
+	}
+}
+
+// A StatusError is returned when a page is answered with a status other than 200 OK
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("fetching %s failed with %s", e.URL, e.Status)
+}
+
+// A ParseError is returned when a page was fetched but could not be read or
+// parsed, most likely as HN changed its markup. It reads as the error of the
+// parser, which may wrap a failure to read the body.
+type ParseError struct {
+	URL string
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// do sends a request once the politeness and rate limiter allow it, as the
+// logged in user if there is a session
+func (c *Client) do(httpClient *http.Client, req *http.Request) (*http.Response, error) {
+	if err := c.spendRequest(); err != nil {
+		return nil, err
+	}
+
+	if c.Limiter != nil {
+		c.Limiter.Wait()
+	}
+
+	if c.Session != "" {
+		req.AddCookie(&http.Cookie{Name: sessionCookie, Value: c.Session})
+	}
+
+	return httpClient.Do(req)
+}
+
+// limitBody fails reading a body, or a body of length, larger than the response size limit of the client
+func (c *Client) limitBody(u string, body io.Reader, length int64) (io.Reader, error) {
+	max := c.Limits.ResponseBytes
+	if max <= 0 {
+		return body, nil
+	}
+
+	sizeErr := &LimitError{URL: u, Limit: "response size", Max: max}
+	if length > max {
+		return nil, sizeErr
+	}
+	return &limitedReader{r: body, max: max, err: sizeErr}, nil
+}
+
+// parsePage parses a page as a whole, within the node limit of the client
+func (c *Client) parsePage(u string, body io.Reader) (*html.Node, error) {
+	node, err := html.Parse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if max := c.Limits.Nodes; max > 0 && countNodes(node) > max {
+		return nil, &LimitError{URL: u, Limit: "node count", Max: int64(max)}
+	}
+
+	return node, nil
+}
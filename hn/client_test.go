@@ -0,0 +1,32 @@
+package hn
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFetchPostsWithoutRows(t *testing.T) {
+	// The markup of the front page, with rows of a class the profile does not know
+	page := strings.ReplaceAll(string(readFrontPage(t)), `class="athing"`, `class="story"`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(page))
+	}))
+	defer server.Close()
+
+	for _, streaming := range []bool{false, true} {
+		options := []Option{WithBaseURL(server.URL), WithHTTPClient(server.Client())}
+		if streaming {
+			options = append(options, WithStreaming())
+		}
+		client := NewClient(options...)
+
+		posts, err := client.FetchPosts(SectionTop, 30)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("streaming %v: got %d posts and error %v, want a *ParseError", streaming, len(posts), err)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package hn
+
+import (
+	"fmt"
+	"golang.org/x/net/html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Preview is what a story links to, as its OpenGraph tags describe it for
+// link previews. Error is why it could not be read, the story is still listed.
+type Preview struct {
+	Title       string `json:",omitempty"`
+	Description string `json:",omitempty"`
+	Image       string `json:",omitempty"`
+	SiteName    string `json:",omitempty"`
+	Favicon     string `json:",omitempty"`
+	Error       string `json:",omitempty"`
+}
+
+// An Enricher fetches the pages stories link to, which are on other sites
+// than HN, so it has its own HTTP client, limits and rate of requests
+type Enricher struct {
+	HTTPClient *http.Client
+
+	// Parallel is how many pages are fetched at once
+	Parallel int
+
+	// MaxBytes is how much of a page is read, the tags are in the head so a
+	// page is read up to here rather than failing
+	MaxBytes int64
+
+	// Paywalls are the domains whose stories are behind a paywall, a domain
+	// includes its subdomains
+	Paywalls []string
+}
+
+// NewEnricher gives up on a page after 5 seconds and reads at most 512 KiB of it
+func NewEnricher() *Enricher {
+	return &Enricher{
+		HTTPClient: &http.Client{Timeout: 5 * time.Second},
+		Parallel:   8,
+		MaxBytes:   512 << 10,
+	}
+}
+
+// Enrich sets the Preview of every story that links to another site, text
+// posts such as Ask HN have none. A page that fails sets the Error of its preview.
+func (e *Enricher) Enrich(posts Posts) {
+	e.each(posts, func(post *Post, u string) {
+		preview, err := e.Fetch(u)
+		if err != nil {
+			preview = &Preview{Error: err.Error()}
+		}
+		post.Preview = preview
+	})
+}
+
+// each calls do with every story that links to another site, Parallel at once
+func (e *Enricher) each(posts Posts, do func(post *Post, u string)) {
+	parallel := e.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	slots := make(chan bool, parallel)
+	var wait sync.WaitGroup
+
+	for i := range posts {
+		u, err := url.Parse(posts[i].URL)
+		if err != nil || !u.IsAbs() || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+
+		wait.Add(1)
+		slots <- true
+		go func(post *Post, u string) {
+			defer wait.Done()
+			defer func() { <-slots }()
+
+			do(post, u)
+		}(&posts[i], u.String())
+	}
+
+	wait.Wait()
+}
+
+// Fetch reads the preview of a page
+func (e *Enricher) Fetch(u string) (*Preview, error) {
+	node, base, err := e.fetchHTML(u)
+	if err != nil {
+		return nil, err
+	}
+
+	return getPreview(node, base), nil
+}
+
+// fetchHTML parses a page up to MaxBytes, and returns where it was after
+// redirects, which is what relative links on it are relative to
+func (e *Enricher) fetchHTML(u string) (*html.Node, *url.URL, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := e.HTTPClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("fetching %s failed with %s", u, resp.Status)
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "" && mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, nil, fmt.Errorf("%s is %s, not a page", u, mediaType)
+	}
+
+	var body io.Reader = resp.Body
+	if e.MaxBytes > 0 {
+		body = io.LimitReader(resp.Body, e.MaxBytes)
+	}
+
+	node, err := html.Parse(body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return node, resp.Request.URL, nil
+}
+
+// getPreview reads the OpenGraph tags, falling back to Twitter cards and then
+// to the title and description of the page
+func getPreview(node *html.Node, base *url.URL) *Preview {
+	meta := make(map[string]string)
+	var title, favicon string
+
+	for _, n := range findNode(node, func(n *html.Node) bool {
+		return n.Type == html.ElementNode && (n.Data == "meta" || n.Data == "title" || n.Data == "link")
+	}) {
+		switch n.Data {
+		case "meta":
+			key := getAttribute("property", n.Attr)
+			if key == nil {
+				key = getAttribute("name", n.Attr)
+			}
+			content := getAttribute("content", n.Attr)
+			if key != nil && content != nil {
+				name := strings.ToLower(key.Val)
+				if _, ok := meta[name]; !ok {
+					meta[name] = strings.TrimSpace(content.Val)
+				}
+			}
+		case "title":
+			if title == "" {
+				title = strings.TrimSpace(textContent(n))
+			}
+		case "link":
+			rel := getAttribute("rel", n.Attr)
+			href := getAttribute("href", n.Attr)
+			if favicon == "" && rel != nil && href != nil {
+				for _, r := range strings.Fields(strings.ToLower(rel.Val)) {
+					if r == "icon" {
+						favicon = href.Val
+					}
+				}
+			}
+		}
+	}
+
+	first := func(values ...string) string {
+		for _, value := range values {
+			if value != "" {
+				return value
+			}
+		}
+		return ""
+	}
+
+	// Sites without an icon link usually still have the conventional one
+	if favicon == "" {
+		favicon = "/favicon.ico"
+	}
+
+	return &Preview{
+		Title:       first(meta["og:title"], meta["twitter:title"], title),
+		Description: first(meta["og:description"], meta["twitter:description"], meta["description"]),
+		Image:       resolveURL(base, first(meta["og:image"], meta["og:image:url"], meta["twitter:image"])),
+		SiteName:    meta["og:site_name"],
+		Favicon:     resolveURL(base, favicon),
+	}
+}
+
+func resolveURL(base *url.URL, ref string) string {
+	if ref == "" {
+		return ""
+	}
+
+	u, err := base.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	return u.String()
+}
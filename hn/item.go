@@ -0,0 +1,394 @@
+package hn
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/net/html"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// An Item is a single story with its text and, optionally, its discussion
+type Item struct {
+	Post
+	Text    string
+	Replies []*Comment `json:",omitempty"`
+}
+
+// A Comment is a reply to a story or to another comment
+type Comment struct {
+	ID      int
+	Author  string
+	Time    time.Time
+	Text    string
+	Replies []*Comment `json:",omitempty"`
+
+	// Position is the place of a comment among its siblings, from 1, HN ranks them best first
+	Position int `json:",omitempty"`
+
+	// Color is the class HN colors the text with, c00 for most comments and
+	// fading through to cdd the more a comment is downvoted
+	Color string `json:",omitempty"`
+
+	// EstimatedScoreBand is how well a comment is thought of, judged by its Color, see ScoreBand
+	EstimatedScoreBand string `json:",omitempty"`
+}
+
+// ParseItemID accepts either an id or an item URL, such as news.ycombinator.com/item?id=1
+func ParseItemID(value string) (int, error) {
+	if id, err := strconv.Atoi(value); err == nil && id > 0 {
+		return id, nil
+	}
+
+	u, err := url.Parse(value)
+	if err != nil || !strings.HasSuffix(u.Path, "/item") {
+		return -1, fmt.Errorf("%q is not an item id or url", value)
+	}
+
+	id, err := strconv.Atoi(u.Query().Get("id"))
+	if err != nil || id < 1 {
+		return -1, fmt.Errorf("%q does not have a valid item id", value)
+	}
+
+	return id, nil
+}
+
+// ParseItem parses an item page, the story with the tree of comments
+func ParseItem(r io.Reader, id int) (*Item, error) {
+	node, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return DefaultProfile.getItem(node, id)
+}
+
+func (p *Profile) getItem(node *html.Node, id int) (*Item, error) {
+	itemNodes := findNode(node, func(n *html.Node) bool {
+		return n.Type == html.ElementNode && hasAttribute("id", strconv.Itoa(id), n.Attr)
+	})
+	if len(itemNodes) != 1 {
+		return nil, fmt.Errorf("item %d was not found", id)
+	}
+	itemNode := itemNodes[0]
+
+	item, err := p.getItemPost(itemNode, nextElementSibling(itemNode), id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only text posts, such as Ask HN, have any text
+	if textNodes := findNode(node, findByClass(p.TopText)); len(textNodes) > 0 {
+		item.Text, err = innerHTML(textNodes[0])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	item.Replies, err = p.getCommentTree(node)
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// getItemPost parses the story of an item from its row and the sub text row after it
+func (p *Profile) getItemPost(itemNode *html.Node, subTextRow *html.Node, id int) (*Item, error) {
+	title, err := p.getTitle(itemNode)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := p.getURL(itemNode)
+	if err != nil {
+		return nil, err
+	}
+
+	if subTextRow == nil {
+		return nil, errors.New("item does not have a sub text row")
+	}
+
+	item := &Item{
+		Post: Post{
+			ID:       id,
+			Title:    title,
+			URL:      u,
+			Author:   "N/A",
+			Points:   -1,
+			Comments: -1,
+		},
+	}
+
+	isAd, err := p.isAdvertisement(subTextRow)
+	if err != nil {
+		return nil, err
+	} else if !isAd {
+		item.Author, err = p.getAuthor(subTextRow)
+		if err != nil {
+			return nil, err
+		}
+
+		item.Points, err = p.getPoints(subTextRow)
+		if err != nil {
+			return nil, err
+		}
+
+		item.Comments, err = p.getComments(subTextRow)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	item.Post.Time, err = p.getTime(subTextRow)
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// getTime parses the exact time from the title of the age, e.g. "3 hours ago"
+func (p *Profile) getTime(node *html.Node) (time.Time, error) {
+	nodes := findNode(node, findByClass(p.Age))
+	if len(nodes) == 0 {
+		return time.Time{}, errors.New("age node was not found")
+	}
+
+	title := getAttribute("title", nodes[0].Attr)
+	if title == nil {
+		return time.Time{}, errors.New("age node does not have a title attribute")
+	}
+
+	// Newer markup appends the unix time, e.g. "2023-10-25T12:34:56 1698237296"
+	fields := strings.Fields(title.Val)
+	if len(fields) == 0 {
+		return time.Time{}, errors.New("age node title is empty")
+	}
+
+	t, err := time.Parse("2006-01-02T15:04:05", fields[0])
+	if err != nil {
+		return time.Time{}, errors.New("age failed to convert to time")
+	}
+
+	return t, nil
+}
+
+// getCommentTree nests the comments, which are flat rows indented by depth
+func (p *Profile) getCommentTree(node *html.Node) ([]*Comment, error) {
+	tree := p.newCommentTree()
+	for _, row := range findNode(node, findByClassName(p.Comment)) {
+		if err := tree.add(row); err != nil {
+			return nil, err
+		}
+	}
+
+	return tree.replies, nil
+}
+
+// A commentTree nests comment rows as they are read
+type commentTree struct {
+	profile *Profile
+	replies []*Comment
+
+	// parents[depth] is the last comment seen at that depth
+	parents []*Comment
+}
+
+func (p *Profile) newCommentTree() *commentTree {
+	return &commentTree{profile: p, replies: make([]*Comment, 0), parents: make([]*Comment, 0)}
+}
+
+func (t *commentTree) add(row *html.Node) error {
+	comment, depth, err := t.profile.getComment(row)
+	if err != nil {
+		return err
+	}
+
+	if depth > len(t.parents) {
+		depth = len(t.parents)
+	}
+	t.parents = append(t.parents[:depth], comment)
+
+	if depth == 0 {
+		t.replies = append(t.replies, comment)
+		comment.Position = len(t.replies)
+	} else {
+		parent := t.parents[depth-1]
+		parent.Replies = append(parent.Replies, comment)
+		comment.Position = len(parent.Replies)
+	}
+
+	return nil
+}
+
+func (p *Profile) getComment(row *html.Node) (*Comment, int, error) {
+	id, err := getID(row)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	depth, err := p.getDepth(row)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	comment := &Comment{ID: id}
+
+	// Deleted and flagged comments have neither an author nor any text
+	if nodes := findNode(row.FirstChild, findByClass(p.Author)); len(nodes) > 0 && nodes[0].FirstChild != nil {
+		comment.Author = nodes[0].FirstChild.Data
+	}
+
+	if t, err := p.getTime(row.FirstChild); err == nil {
+		comment.Time = t
+	}
+
+	if nodes := findNode(row.FirstChild, findByClassName(p.CommentText)); len(nodes) > 0 {
+		comment.Text, err = innerHTML(nodes[0])
+		if err != nil {
+			return nil, -1, err
+		}
+
+		comment.Color = getColor(nodes[0])
+		comment.EstimatedScoreBand = ScoreBand(comment.Color)
+	}
+
+	return comment, depth, nil
+}
+
+// getDepth reads the indentation, either an attribute or the width of a spacer image
+func (p *Profile) getDepth(row *html.Node) (int, error) {
+	nodes := findNode(row.FirstChild, findByClass(p.Indent))
+	if len(nodes) == 0 {
+		return -1, errors.New("comment indentation node was not found")
+	}
+
+	if indent := getAttribute("indent", nodes[0].Attr); indent != nil {
+		return strconv.Atoi(indent.Val)
+	}
+
+	images := findNode(nodes[0].FirstChild, func(n *html.Node) bool {
+		return n.Type == html.ElementNode && n.Data == "img"
+	})
+	if len(images) == 0 {
+		return -1, errors.New("comment indentation image was not found")
+	}
+
+	width := getAttribute("width", images[0].Attr)
+	if width == nil {
+		return -1, errors.New("comment indentation image does not have a width")
+	}
+
+	w, err := strconv.Atoi(width.Val)
+	if err != nil {
+		return -1, errors.New("comment indentation failed to convert to integer")
+	}
+
+	return w / 40, nil
+}
+
+// innerHTML renders the children of a node, leaving out the reply link comments include
+func innerHTML(node *html.Node) (string, error) {
+	var b strings.Builder
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && hasAttribute("class", "reply", child.Attr) {
+			continue
+		}
+
+		if err := html.Render(&b, child); err != nil {
+			return "", err
+		}
+	}
+
+	return strings.TrimSpace(b.String()), nil
+}
+
+// findByClassName matches one of the classes of an element, unlike findByClass
+// which matches the class attribute exactly
+func findByClassName(class string) comparator {
+	return func(n *html.Node) bool {
+		if n.Type != html.ElementNode {
+			return false
+		}
+
+		attr := getAttribute("class", n.Attr)
+		if attr == nil {
+			return false
+		}
+
+		return contains(strings.Fields(attr.Val), class)
+	}
+}
+
+func nextElementSibling(node *html.Node) *html.Node {
+	for node = node.NextSibling; node != nil; node = node.NextSibling {
+		if node.Type == html.ElementNode {
+			return node
+		}
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Bands of the score of a comment, which HN does not show, estimated from
+// how faded its text is. Faded comments are hidden by most readers.
+const (
+	ScoreBandNormal   = "normal"
+	ScoreBandLow      = "low"
+	ScoreBandNegative = "negative"
+	ScoreBandBuried   = "buried"
+)
+
+var colorClass = regexp.MustCompile(`^c[0-9a-f]{2}$`)
+
+// getColor returns the color class of the text of a comment, such as c5a
+func getColor(node *html.Node) string {
+	class := getAttribute("class", node.Attr)
+	if class == nil {
+		return ""
+	}
+
+	for _, name := range strings.Fields(class.Val) {
+		if colorClass.MatchString(name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// ScoreBand estimates the score of a comment from its color class. Comments
+// at 1 point or more are black, c00, and each point below it is a lighter
+// grey, from c5a at 0 points to cdd.
+func ScoreBand(color string) string {
+	if !colorClass.MatchString(color) {
+		return ""
+	}
+
+	grey, err := strconv.ParseUint(color[1:], 16, 8)
+	if err != nil {
+		return ""
+	}
+
+	switch {
+	case grey == 0:
+		return ScoreBandNormal
+	case grey <= 0x73:
+		return ScoreBandLow
+	case grey <= 0x88:
+		return ScoreBandNegative
+	}
+	return ScoreBandBuried
+}
@@ -0,0 +1,134 @@
+package hn
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// A Job is a post of the jobs section, or a job advertised on the front page,
+// with the company, role and location its title names
+type Job struct {
+	Post
+
+	// Company is who is hiring, Batch the batch of YC it was in, such as S24
+	Company string `json:",omitempty"`
+	Batch   string `json:",omitempty"`
+
+	Role     string `json:",omitempty"`
+	Location string `json:",omitempty"`
+
+	// Remote is whether the title says the job can be done remotely
+	Remote bool `json:",omitempty"`
+}
+
+// JobPatterns are the ways titles of jobs are usually written, such as
+// "Acme (YC S24) Is Hiring a Senior Engineer in Berlin" or "Acme – Designer – Remote".
+// Their named groups company, batch, role and location are the fields of a Job.
+var JobPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^(?P<company>.+?)(?:\s*\(YC (?P<batch>[A-Z]\d{2})\))?\s+(?i:is|are)\s+(?i:hiring)\b\s*(?:(?i:an?|the)\s+)?(?P<role>.*)$`),
+	regexp.MustCompile(`^(?P<company>.+?)(?:\s*\(YC (?P<batch>[A-Z]\d{2})\))?\s+[|–—-]\s+(?P<role>.+)$`),
+}
+
+// jobGroups are the named groups a job pattern takes its fields from
+var jobGroups = []string{"company", "batch", "role", "location"}
+
+// CompileJobPattern compiles a pattern for the titles of jobs, which must have
+// at least one of the named groups company, batch, role or location
+func CompileJobPattern(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, group := range jobGroups {
+		if re.SubexpIndex(group) >= 0 {
+			return re, nil
+		}
+	}
+	return nil, fmt.Errorf("pattern %q has none of the groups (?P<company>), (?P<batch>), (?P<role>) or (?P<location>)", pattern)
+}
+
+var (
+	// batchPattern finds the batch of a company anywhere in a title no pattern matches
+	batchPattern = regexp.MustCompile(`\(YC ([A-Z]\d{2})\)`)
+
+	// locationPatterns split where a job is off the end of its role, after a
+	// separator, in parentheses or after "in"
+	locationPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`^(.*?)\s+[|–—-]\s+(.+)$`),
+		regexp.MustCompile(`^(.*?)\s*\(([^()]+)\)$`),
+		regexp.MustCompile(`^(.*?)\s+(?:in|based in|at)\s+(\p{Lu}.*)$`),
+	}
+
+	remotePattern = regexp.MustCompile(`(?i)\bremote\b`)
+)
+
+// IsJob is whether a post is a job, listed in the jobs section or advertised
+// on the front page, where jobs have no author, points or comments
+func IsJob(post Post) bool {
+	return post.Section == SectionJobs || (post.Author == "N/A" && post.Points < 0)
+}
+
+// ParseJob parses the title of a job with the first of patterns that matches
+// it, JobPatterns when there are none. A title none of them match is kept as
+// the job, with only the batch of its company when it has one.
+func ParseJob(post Post, patterns []*regexp.Regexp) Job {
+	if len(patterns) == 0 {
+		patterns = JobPatterns
+	}
+
+	job := Job{Post: post}
+	title := strings.TrimSpace(post.Title)
+
+	matched := false
+	for _, pattern := range patterns {
+		match := pattern.FindStringSubmatch(title)
+		if match == nil {
+			continue
+		}
+
+		group := func(name string) string {
+			if i := pattern.SubexpIndex(name); i >= 0 {
+				return strings.TrimSpace(match[i])
+			}
+			return ""
+		}
+		job.Company = group("company")
+		job.Batch = group("batch")
+		job.Role = group("role")
+		job.Location = group("location")
+		matched = true
+		break
+	}
+
+	if job.Batch == "" {
+		if match := batchPattern.FindStringSubmatch(title); match != nil {
+			job.Batch = match[1]
+		}
+	}
+
+	// Patterns rarely tell the role from where it is, so a role without a location usually ends with it
+	if matched && job.Location == "" {
+		for _, pattern := range locationPatterns {
+			if match := pattern.FindStringSubmatch(job.Role); match != nil && match[1] != "" {
+				job.Role, job.Location = match[1], match[2]
+				break
+			}
+		}
+	}
+
+	job.Remote = remotePattern.MatchString(title)
+	return job
+}
+
+// ParseJobs parses the posts that are jobs, leaving out the rest
+func ParseJobs(posts Posts, patterns []*regexp.Regexp) []Job {
+	jobs := make([]Job, 0)
+	for _, post := range posts {
+		if IsJob(post) {
+			jobs = append(jobs, ParseJob(post, patterns))
+		}
+	}
+	return jobs
+}
@@ -0,0 +1,150 @@
+package hn
+
+import (
+	"fmt"
+	"golang.org/x/net/html"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A RateLimiter is a token bucket, shared by every request a client makes
+// however many goroutines are fetching, so HN is not hammered
+type RateLimiter struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter allows rate requests per second, with bursts of up to burst requests
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a request is allowed. Each caller reserves a token, which
+// may leave the bucket in debt, so waiting callers are served in order.
+func (l *RateLimiter) Wait() {
+	l.mutex.Lock()
+
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens--
+
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+
+	l.mutex.Unlock()
+
+	time.Sleep(wait)
+}
+
+// ParseRate parses a rate such as 1rps, 30rpm or 0.5rps to requests per
+// second, 0 or unlimited means no limit
+func ParseRate(value string) (float64, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "0" || value == "unlimited" {
+		return 0, nil
+	}
+
+	units := map[string]float64{"rps": 1, "rpm": 1.0 / 60, "rph": 1.0 / 3600}
+	for suffix, scale := range units {
+		if !strings.HasSuffix(value, suffix) {
+			continue
+		}
+
+		n, err := strconv.ParseFloat(strings.TrimSuffix(value, suffix), 64)
+		if err != nil || n < 0 || math.IsInf(n, 0) || math.IsNaN(n) {
+			break
+		}
+
+		return n * scale, nil
+	}
+
+	return 0, fmt.Errorf("invalid rate %q, e.g. 1rps, 30rpm or unlimited", value)
+}
+
+// Limits protect a client from pathological or malicious pages, such as those
+// of a misbehaving mirror. A zero limit is unlimited.
+type Limits struct {
+	// ResponseBytes is the largest response body read
+	ResponseBytes int64
+	// Nodes is the most HTML nodes a page may parse into
+	Nodes int
+	// CommentDepth is the deepest a comment may be nested
+	CommentDepth int
+	// TextLength is the most characters of a title or author kept, longer ones are cut short rather than failing
+	TextLength int
+}
+
+// DefaultLimits are well beyond the largest pages on HN
+var DefaultLimits = Limits{
+	ResponseBytes: 16 << 20,
+	Nodes:         1000000,
+	CommentDepth:  200,
+	TextLength:    256,
+}
+
+// cutPost cuts the title and author of a post to the text length limit
+func (l Limits) cutPost(post *Post) {
+	post.Title = truncate(post.Title, l.TextLength)
+	post.Author = truncate(post.Author, l.TextLength)
+}
+
+// A LimitError is returned when a page exceeds a limit
+type LimitError struct {
+	URL   string
+	Limit string
+	Max   int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s exceeds the %s limit of %d", e.URL, e.Limit, e.Max)
+}
+
+// limitedReader fails, rather than truncating, once more than max bytes are read
+type limitedReader struct {
+	r    io.Reader
+	read int64
+	max  int64
+	err  error
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.max {
+		return n, l.err
+	}
+	return n, err
+}
+
+func countNodes(node *html.Node) int {
+	count := 1
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		count += countNodes(child)
+	}
+	return count
+}
+
+func commentDepth(comments []*Comment) int {
+	depth := 0
+	for _, comment := range comments {
+		if d := 1 + commentDepth(comment.Replies); d > depth {
+			depth = d
+		}
+	}
+	return depth
+}
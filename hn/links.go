@@ -0,0 +1,76 @@
+package hn
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// A LinkStatus is what became of the page a story links to
+type LinkStatus struct {
+	// Status is the HTTP status of the page, after redirects
+	Status int `json:",omitempty"`
+	// FinalURL is where the redirects ended
+	FinalURL string `json:",omitempty"`
+	// Dead is a page that is gone or failing, a missing page, a server error or no answer at all
+	Dead bool
+	// Paywalled is a page on a domain of the paywalls of the Enricher
+	Paywalled bool
+	Error     string `json:",omitempty"`
+}
+
+// CheckLinks sets the Link of every story that links to another site, text
+// posts such as Ask HN have none
+func (e *Enricher) CheckLinks(posts Posts) {
+	e.each(posts, func(post *Post, u string) {
+		status := e.CheckLink(u)
+		post.Link = &status
+	})
+}
+
+// CheckLink sends a HEAD request to a page, or a GET for servers that do not
+// take HEAD, without reading the page
+func (e *Enricher) CheckLink(u string) LinkStatus {
+	resp, err := e.request(http.MethodHead, u)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp.Body.Close()
+		resp, err = e.request(http.MethodGet, u)
+	}
+	if err != nil {
+		return LinkStatus{Dead: true, Paywalled: e.isPaywalled(u), Error: err.Error()}
+	}
+	resp.Body.Close()
+
+	final := resp.Request.URL.String()
+	code := resp.StatusCode
+	return LinkStatus{
+		Status:    code,
+		FinalURL:  final,
+		Dead:      code == http.StatusNotFound || code == http.StatusGone || code >= 500,
+		Paywalled: e.isPaywalled(u) || e.isPaywalled(final),
+	}
+}
+
+func (e *Enricher) request(method string, u string) (*http.Response, error) {
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	return e.HTTPClient.Do(req)
+}
+
+func (e *Enricher) isPaywalled(u string) bool {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+	for _, domain := range e.Paywalls {
+		domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package hn
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// count is the first number in a text, with its digits grouped by commas,
+// dots, apostrophes or spaces, as sites in other languages write them
+var count = regexp.MustCompile(`\d+(?:[,.'\s]\d{3})*`)
+
+// errNoNumber is returned for a text without a number, such as "discuss"
+var errNoNumber = errors.New("text has no number")
+
+// parseCount parses the number in a text such as "1,234 points", "12 comments"
+// or "3.", whatever its words, plural or not, and however non-breaking spaces
+// and thousands are written
+func parseCount(text string) (int, error) {
+	text = strings.Map(func(r rune) rune {
+		// Non-breaking and thin spaces are spaces for the sake of numbers
+		if r == '\u00a0' || r == '\u202f' || r == '\u2009' {
+			return ' '
+		}
+		return r
+	}, text)
+
+	number := count.FindString(text)
+	if number == "" {
+		return -1, errNoNumber
+	}
+
+	digits := strings.Map(func(r rune) rune {
+		if r < '0' || r > '9' {
+			return -1
+		}
+		return r
+	}, number)
+	return strconv.Atoi(digits)
+}
+
+// truncate cuts text to at most length characters, never within one. A length of 0 keeps it whole.
+func truncate(text string, length int) string {
+	if length <= 0 || utf8.RuneCountInString(text) <= length {
+		return text
+	}
+
+	characters := 0
+	for i := range text {
+		if characters == length {
+			return text[:i]
+		}
+		characters++
+	}
+	return text
+}
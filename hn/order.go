@@ -0,0 +1,96 @@
+package hn
+
+import "sort"
+
+// Sort orders posts by rank and then by id, so the same posts always come out
+// in the same order however they were fetched. Posts without a rank, such as
+// items, come last.
+func (posts Posts) Sort() {
+	sort.SliceStable(posts, func(i, j int) bool {
+		a, b := posts[i], posts[j]
+		if (a.Rank > 0) != (b.Rank > 0) {
+			return a.Rank > 0
+		}
+		if a.Rank != b.Rank {
+			return a.Rank < b.Rank
+		}
+		return a.ID < b.ID
+	})
+}
+
+// Dedupe removes repeated posts by id, keeping the first. Pages fetched a moment
+// apart can both list a post that moved between them.
+func (posts Posts) Dedupe() Posts {
+	seen := make(map[int]bool, len(posts))
+	deduped := make(Posts, 0, len(posts))
+
+	for _, post := range posts {
+		if post.ID != 0 && seen[post.ID] {
+			continue
+		}
+		seen[post.ID] = true
+		deduped = append(deduped, post)
+	}
+
+	return deduped
+}
+
+// MergeSections merges the posts of several sections into one list without
+// duplicates, each post listing the sections it was in and its rank there.
+// Posts keep the rank of the first section they were in and are sorted by it,
+// and then by id, like the posts of a single section.
+func MergeSections(sections []string, lists []Posts) Posts {
+	index := make(map[int]int)
+	merged := make(Posts, 0)
+
+	for i, posts := range lists {
+		for _, post := range posts {
+			listing := Listing{Section: sections[i], Rank: post.Rank}
+
+			// Posts without an id, such as some job ads, can not be matched up
+			if j, ok := index[post.ID]; ok && post.ID != 0 {
+				merged[j].Sections = append(merged[j].Sections, listing)
+				merged[j].SecondChance = merged[j].SecondChance || post.SecondChance
+				continue
+			}
+
+			post.Sections = []Listing{listing}
+			index[post.ID] = len(merged)
+			merged = append(merged, post)
+		}
+	}
+
+	merged.Sort()
+	return merged
+}
+
+// SplitSections undoes MergeSections, listing each post under every section it
+// was in with its rank there, in order of rank. The lists are keyed by section,
+// such as SectionTop, and every section has one, even when none of its posts
+// are left.
+func SplitSections(sections []string, posts Posts) map[string]Posts {
+	lists := make(map[string]Posts, len(sections))
+	for _, section := range sections {
+		lists[section] = make(Posts, 0)
+	}
+
+	for _, post := range posts {
+		// Posts of a single section are not merged, they only have their section
+		listings := post.Sections
+		if len(listings) == 0 && post.Section != "" {
+			listings = []Listing{{Section: post.Section, Rank: post.Rank}}
+		}
+
+		for _, listing := range listings {
+			listed := post
+			listed.Section, listed.Rank = listing.Section, listing.Rank
+			lists[listing.Section] = append(lists[listing.Section], listed)
+		}
+	}
+
+	for _, list := range lists {
+		list.Sort()
+	}
+
+	return lists
+}
@@ -0,0 +1,107 @@
+package hn
+
+import (
+	"reflect"
+	"testing"
+)
+
+func ids(posts Posts) []int {
+	ids := make([]int, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+	return ids
+}
+
+func TestPostsSort(t *testing.T) {
+	tests := []struct {
+		name  string
+		posts Posts
+		want  []int
+	}{
+		{
+			name:  "by rank",
+			posts: Posts{{ID: 1, Rank: 3}, {ID: 2, Rank: 1}, {ID: 3, Rank: 2}},
+			want:  []int{2, 3, 1},
+		},
+		{
+			name:  "by id within a rank",
+			posts: Posts{{ID: 9, Rank: 1}, {ID: 4, Rank: 2}, {ID: 5, Rank: 1}},
+			want:  []int{5, 9, 4},
+		},
+		{
+			name:  "without a rank last",
+			posts: Posts{{ID: 1}, {ID: 7, Rank: 2}, {ID: 3}, {ID: 8, Rank: 1}},
+			want:  []int{8, 7, 1, 3},
+		},
+		{
+			name:  "empty",
+			posts: Posts{},
+			want:  []int{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.posts.Sort()
+			if got := ids(test.posts); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPostsDedupe(t *testing.T) {
+	tests := []struct {
+		name  string
+		posts Posts
+		want  Posts
+	}{
+		{
+			name:  "keeps the first",
+			posts: Posts{{ID: 1, Rank: 30}, {ID: 2, Rank: 31}, {ID: 1, Rank: 32}},
+			want:  Posts{{ID: 1, Rank: 30}, {ID: 2, Rank: 31}},
+		},
+		{
+			name:  "keeps the order",
+			posts: Posts{{ID: 3}, {ID: 1}, {ID: 3}, {ID: 2}, {ID: 1}},
+			want:  Posts{{ID: 3}, {ID: 1}, {ID: 2}},
+		},
+		{
+			name:  "without duplicates",
+			posts: Posts{{ID: 1}, {ID: 2}},
+			want:  Posts{{ID: 1}, {ID: 2}},
+		},
+		{
+			name:  "empty",
+			posts: Posts{},
+			want:  Posts{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.posts.Dedupe(); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", ids(got), ids(test.want))
+			}
+		})
+	}
+}
+
+func TestMergeSections(t *testing.T) {
+	sections := []string{SectionTop, SectionShow}
+	lists := []Posts{
+		{{ID: 5, Rank: 2}, {ID: 3, Rank: 3}},
+		{{ID: 7, Rank: 1}, {ID: 5, Rank: 2}, {ID: 2, Rank: 3}},
+	}
+
+	merged := MergeSections(sections, lists)
+	if got, want := ids(merged), []int{7, 5, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	want := []Listing{{Section: SectionTop, Rank: 2}, {Section: SectionShow, Rank: 2}}
+	if got := merged[1].Sections; !reflect.DeepEqual(got, want) {
+		t.Errorf("post 5 listed in %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,441 @@
+package hn
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/net/html"
+	"io"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// We must export it to allow JSON to marshal it
+type Post struct {
+	ID       int
+	Title    string
+	URL      string
+	Author   string
+	Points   int
+	Comments int
+	Rank     int
+	Time     time.Time
+
+	// Section is the section a post was listed in, the first of them when sections are merged
+	Section string `json:",omitempty"`
+
+	// SecondChance is whether a post on the front page is most likely there from the second-chance pool, see IsSecondChance
+	SecondChance bool `json:",omitempty"`
+
+	// Sections lists where a post was listed, when posts of several sections are merged
+	Sections []Listing `json:",omitempty"`
+
+	// Preview is what the story links to, when an Enricher has read it
+	Preview *Preview `json:",omitempty"`
+
+	// Link is whether the story still links to a live page, when an Enricher has checked it
+	Link *LinkStatus `json:",omitempty"`
+
+	// Archive is how saving the story to the Wayback Machine went, when an Archiver has saved it
+	Archive *ArchiveStatus `json:",omitempty"`
+
+	// TopComment is the highest ranked comment on the story, when its item page has been fetched for it
+	TopComment *TopComment `json:",omitempty"`
+}
+
+type Posts []Post
+
+// A Listing is where a post was listed, the section and its rank there
+type Listing struct {
+	Section string
+	Rank    int
+}
+
+type comparator func(node *html.Node) bool
+
+func findNode(n *html.Node, compare comparator) []*html.Node {
+	matches := make([]*html.Node, 0)
+	if n == nil {
+		return matches
+	}
+
+	if compare(n) {
+		matches = append(matches, n)
+	}
+
+	matches = append(matches, findNode(n.FirstChild, compare)...)
+	matches = append(matches, findNode(n.NextSibling, compare)...)
+
+	return matches
+}
+
+func prevSiblingUntil(node *html.Node, compare comparator) *html.Node {
+	for node.PrevSibling != nil {
+		node = node.PrevSibling
+		if node != nil && compare(node) {
+			return node
+		}
+	}
+	return nil
+}
+
+func hasAttribute(key string, value string, attrs []html.Attribute) bool {
+	attr := getAttribute(key, attrs)
+
+	if attr == nil {
+		return false
+	}
+
+	if attr.Val != value {
+		return false
+	}
+
+	return true
+}
+
+/**
+ * Get an attribute
+ *
+ * If we are doing this a lot, it probably makes sense to create a hash table
+ * to look up the attributes, returning look up to on Θ(1) vs Θ(n) with an array
+ */
+func getAttribute(key string, attrs []html.Attribute) *html.Attribute {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return &attr
+		}
+	}
+
+	return nil
+}
+
+func findByClass(class string) comparator {
+	return func(n *html.Node) bool {
+		if n.Type != html.ElementNode {
+			return false
+		}
+
+		return hasAttribute("class", class, n.Attr)
+	}
+}
+
+func (p *Profile) getURL(node *html.Node) (string, error) {
+	nodes := p.titleLinks(node)
+	if len(nodes) != 1 {
+		return "", errors.New("uri nodes length is not exactly one")
+	}
+
+	node = nodes[0]
+	if node.Type != html.ElementNode {
+		return "", errors.New("uri node type is not an element node")
+	}
+
+	if node.Data != "a" {
+		return "", errors.New("uri node is not an anchor")
+	}
+
+	href := getAttribute("href", node.Attr)
+	if href == nil {
+		return "", errors.New("uri node does not have a href attribute")
+	}
+
+	u, err := url.Parse(href.Val)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// titleLinks finds the link of the title, by its class or that of an element around it
+func (p *Profile) titleLinks(node *html.Node) []*html.Node {
+	nodes := findNode(node.FirstChild, findByClass(p.Title))
+	for i, n := range nodes {
+		if n.Data == "a" {
+			continue
+		}
+		if links := findNode(n.FirstChild, func(n *html.Node) bool { return n.Type == html.ElementNode && n.Data == "a" }); len(links) > 0 {
+			nodes[i] = links[0]
+		}
+	}
+	return nodes
+}
+
+func (p *Profile) getTitle(node *html.Node) (string, error) {
+	nodes := p.titleLinks(node)
+	if len(nodes) != 1 {
+		return "", errors.New("author nodes length is not exactly one")
+	}
+
+	firstChild := nodes[0].FirstChild
+	if firstChild == nil {
+		return "", errors.New("author node does not have any children")
+	}
+
+	if firstChild.Type != html.TextNode {
+		return "", errors.New("author node child is not a text node")
+	}
+
+	return firstChild.Data, nil
+}
+
+func (p *Profile) getAuthor(node *html.Node) (string, error) {
+	nodes := findNode(node, findByClass(p.Author))
+	if len(nodes) != 1 {
+		return "", errors.New("author nodes length is not exactly one")
+	}
+
+	firstChild := nodes[0].FirstChild
+	if firstChild == nil {
+		return "", errors.New("author node does not have any children")
+	}
+
+	if firstChild.Type != html.TextNode {
+		return "", errors.New("author node child is not a text node")
+	}
+
+	return firstChild.Data, nil
+}
+
+func getID(node *html.Node) (int, error) {
+	attr := getAttribute("id", node.Attr)
+	if attr == nil {
+		return -1, errors.New("post node does not have an id attribute")
+	}
+
+	id, err := strconv.Atoi(attr.Val)
+	if err != nil {
+		return -1, errors.New("id failed to convert to integer")
+	}
+
+	return id, nil
+}
+
+func (p *Profile) getRank(node *html.Node) (int, error) {
+	nodes := findNode(node.FirstChild, findByClass(p.Rank))
+	if len(nodes) != 1 {
+		return -1, errors.New("rank nodes length is not exactly one")
+	}
+
+	firstChild := nodes[0].FirstChild
+	if firstChild == nil {
+		return -1, errors.New("rank node does not have any children")
+	}
+
+	if firstChild.Type != html.TextNode {
+		return -1, errors.New("rank node child is not a text node")
+	}
+
+	rank, err := parseCount(firstChild.Data)
+	if err != nil {
+		return -1, errors.New("rank failed to convert to integer")
+	}
+
+	return rank, nil
+}
+
+func (p *Profile) getPoints(node *html.Node) (int, error) {
+	nodes := findNode(node, findByClass(p.Score))
+	if len(nodes) != 1 {
+		return -1, errors.New("point nodes length is not exactly one")
+	}
+
+	firstChild := nodes[0].FirstChild
+	if firstChild == nil {
+		return -1, errors.New("point node does not have any children")
+	}
+
+	if firstChild.Type != html.TextNode {
+		return -1, errors.New("point node child is not a text node")
+	}
+
+	points, err := parseCount(firstChild.Data)
+	if err != nil {
+		return -1, errors.New("point failed to convert to integer")
+	}
+
+	return points, nil
+}
+
+// isAdvertisement is whether a post is a job ad, told by its markup rather
+// than its words, as ads have neither an author nor a score in any language
+func (p *Profile) isAdvertisement(node *html.Node) (bool, error) {
+	subTextNode := findNode(node, findByClass(p.SubText))
+	if len(subTextNode) != 1 {
+		return false, errors.New("sub text nodes length is not exactly one")
+	}
+
+	authors := findNode(subTextNode[0], findByClass(p.Author))
+	scores := findNode(subTextNode[0], findByClass(p.Score))
+	return len(authors) == 0 && len(scores) == 0, nil
+}
+
+func (p *Profile) getCommentNode(node *html.Node) (*html.Node, error) {
+	subTextNode := findNode(node, findByClass(p.SubText))
+	if len(subTextNode) != 1 {
+		return nil, errors.New("comment parent nodes length is not exactly one")
+	}
+
+	commentNode := prevSiblingUntil(subTextNode[0].LastChild, func(node *html.Node) bool {
+		return node.Type == html.ElementNode
+	})
+	if commentNode == nil {
+		return nil, errors.New("comment node is nil")
+	}
+
+	textNode := commentNode.FirstChild
+	if textNode == nil {
+		return nil, errors.New("comment node does not have any children")
+	}
+
+	if textNode.Type != html.TextNode {
+		return nil, errors.New("comment node child is not a text node")
+	}
+
+	return textNode, nil
+}
+
+func (p *Profile) getComments(node *html.Node) (int, error) {
+	textNode, err := p.getCommentNode(node)
+	if err != nil {
+		return -1, err
+	}
+
+	// A story without comments links to "discuss", or whatever the site calls it
+	comments, err := parseCount(textNode.Data)
+	if err == errNoNumber {
+		return 0, nil
+	} else if err != nil {
+		return -1, errors.New("comments failed to convert to integer")
+	}
+
+	return comments, nil
+}
+
+// ParsePosts parses the posts of a listing, such as the front page
+func ParsePosts(r io.Reader) (Posts, error) {
+	node, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return DefaultProfile.getPosts(node, discardLogger)
+}
+
+// getPosts parses the rows of a listing, logging which fields of each post fell back to defaults
+func (p *Profile) getPosts(node *html.Node, logger *slog.Logger) (Posts, error) {
+	rows := findNode(node, findByClass(p.Post))
+	logger.Debug("rows matched", "selector", "."+p.Post, "count", len(rows))
+
+	// NOTE: we could make this allocation more efficient by passing in the length and allocating up front
+	posts := make(Posts, 0)
+	for _, postNode := range rows {
+		post, ok, err := p.getPost(postNode, postNode.NextSibling.FirstChild, logger)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			posts = append(posts, post)
+		}
+	}
+	return posts, nil
+}
+
+// errNoPosts is the error of a first page without posts, most likely as its
+// rows have a class other than that of the profile
+func (p *Profile) errNoPosts() error {
+	return fmt.Errorf("no posts were found, no row of class %q parsed as one", p.Post)
+}
+
+// getPost parses the row of a post and the first cell of the row after it,
+// with the author, points and comments. A post without it is not listed.
+func (p *Profile) getPost(postNode *html.Node, nextRow *html.Node, logger *slog.Logger) (Post, bool, error) {
+	title, err := p.getTitle(postNode)
+	if err != nil {
+		return Post{}, false, err
+	}
+
+	u, err := p.getURL(postNode)
+	if err != nil {
+		return Post{}, false, err
+	}
+
+	// If nextRow is nil, it's likely we're at the end of the results
+	if nextRow == nil {
+		logger.Debug("row skipped, it has no subtext row", "title", title)
+		return Post{}, false, nil
+	}
+
+	fallbacks := make([]string, 0)
+
+	author := "N/A"
+	points := -1
+	comments := -1
+
+	isAd, err := p.isAdvertisement(nextRow)
+
+	if err != nil {
+		return Post{}, false, err
+	} else if isAd {
+		fallbacks = append(fallbacks, "author", "points", "comments")
+	} else {
+		author, err = p.getAuthor(nextRow)
+		if err != nil {
+			return Post{}, false, err
+		}
+
+		points, err = p.getPoints(nextRow)
+		if err != nil {
+			return Post{}, false, err
+		}
+
+		comments, err = p.getComments(nextRow)
+		if err != nil {
+			return Post{}, false, err
+		}
+	}
+
+	rank, err := p.getRank(postNode)
+	if err != nil {
+		return Post{}, false, err
+	}
+
+	id, err := getID(postNode)
+	if err != nil {
+		return Post{}, false, err
+	}
+
+	// The time is only used to filter posts, a post without one is still listed
+	posted, err := p.getTime(nextRow)
+	if err != nil {
+		fallbacks = append(fallbacks, "time")
+	}
+
+	logger.Debug("post", "id", id, "rank", rank, "advertisement", isAd, "fallbacks", fallbacks)
+
+	return Post{
+		ID:       id,
+		Title:    title,
+		URL:      u,
+		Author:   author,
+		Points:   points,
+		Comments: comments,
+		Rank:     rank,
+		Time:     posted,
+	}, true, nil
+}
+
+// getMoreURL returns the link to the next page of a listing, or nothing on the last page
+func (p *Profile) getMoreURL(node *html.Node) string {
+	nodes := findNode(node, findByClass(p.More))
+	if len(nodes) == 0 {
+		return ""
+	}
+
+	href := getAttribute("href", nodes[0].Attr)
+	if href == nil {
+		return ""
+	}
+
+	return href.Val
+}
@@ -0,0 +1,53 @@
+package hn
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParsePostsAdvertisements(t *testing.T) {
+	english := string(readFrontPage(t))
+
+	// A site running the code of HN in another language only changes its words
+	german := strings.NewReplacer(
+		">hide<", ">verbergen<",
+		" points<", " Punkte<",
+		"&nbsp;comments<", "&nbsp;Kommentare<",
+	).Replace(english)
+
+	for _, test := range []struct {
+		name string
+		page string
+	}{
+		{"english", english},
+		{"german", german},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			for _, parse := range []func(r *bytes.Reader) (Posts, error){
+				func(r *bytes.Reader) (Posts, error) { return ParsePosts(r) },
+				func(r *bytes.Reader) (Posts, error) { return ScanPosts(r) },
+			} {
+				posts, err := parse(bytes.NewReader([]byte(test.page)))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				ads := 0
+				for _, post := range posts {
+					isAd := post.Author == "N/A" && post.Points == -1 && post.Comments == -1
+					if isAd {
+						ads++
+					}
+					if isAd != (post.Rank == 7) {
+						t.Errorf("post %d at rank %d: author %q, %d points and %d comments", post.ID, post.Rank, post.Author, post.Points, post.Comments)
+					}
+				}
+
+				if ads != 1 {
+					t.Errorf("found %d advertisements, want 1", ads)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package hn
+
+// A Processor transforms a post, or drops it by returning false
+type Processor func(post Post) (Post, bool)
+
+// A Pipeline runs each post through its processors in order, a post dropped
+// by one does not reach the next
+type Pipeline []Processor
+
+// Process returns the posts that made it through the pipeline
+func (p Pipeline) Process(posts Posts) Posts {
+	if len(p) == 0 {
+		return posts
+	}
+
+	processed := make(Posts, 0, len(posts))
+Posts:
+	for _, post := range posts {
+		for _, process := range p {
+			var keep bool
+			if post, keep = process(post); !keep {
+				continue Posts
+			}
+		}
+		processed = append(processed, post)
+	}
+
+	return processed
+}
+
+// WithProcessors runs the posts of every section the client lists through the
+// processors, such as a filter on points or a rewrite of titles. The
+// submissions of a user are left as they are, Submit looks for a post in them.
+func WithProcessors(processors ...Processor) Option {
+	return func(c *Client) {
+		c.Pipeline = append(c.Pipeline, processors...)
+	}
+}
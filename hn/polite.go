@@ -0,0 +1,170 @@
+package hn
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Politeness keeps an automated client from turning into an abusive crawler,
+// on top of the rate limiter. A zero value is no limit.
+type Politeness struct {
+	// Delay is the least time between the start of two requests
+	Delay time.Duration
+	// Pages is the most pages the client fetches, such as a page of a listing or an item
+	Pages int
+	// Requests is the most requests the client sends, pages, logins and forms alike
+	Requests int
+}
+
+// A BudgetError is returned once the client has spent a budget of its politeness
+type BudgetError struct {
+	Budget string
+	Max    int
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("the %s budget of %d is spent, no more are sent", e.Budget, e.Max)
+}
+
+// WithPoliteness delays requests and caps how many pages and requests the client sends
+func WithPoliteness(politeness Politeness) Option {
+	return func(c *Client) {
+		c.Politeness = politeness
+	}
+}
+
+// spending is what the client spent of its politeness, shared by every goroutine using it
+type spending struct {
+	mutex    sync.Mutex
+	pages    int
+	requests int
+	last     time.Time
+}
+
+// spendPage counts a page against the page budget, before it is requested
+func (c *Client) spendPage() error {
+	if c.Politeness.Pages <= 0 || c.spent == nil {
+		return nil
+	}
+
+	c.spent.mutex.Lock()
+	defer c.spent.mutex.Unlock()
+
+	if c.spent.pages >= c.Politeness.Pages {
+		return &BudgetError{Budget: "page", Max: c.Politeness.Pages}
+	}
+	c.spent.pages++
+	return nil
+}
+
+// spendRequest counts a request against the request budget and waits out the
+// delay. Each caller reserves its start time, so waiting callers are served in order.
+func (c *Client) spendRequest() error {
+	if c.spent == nil {
+		return nil
+	}
+
+	c.spent.mutex.Lock()
+
+	if max := c.Politeness.Requests; max > 0 && c.spent.requests >= max {
+		c.spent.mutex.Unlock()
+		return &BudgetError{Budget: "request", Max: max}
+	}
+	c.spent.requests++
+
+	var wait time.Duration
+	now := time.Now()
+	if next := c.spent.last.Add(c.Politeness.Delay); c.Politeness.Delay > 0 && next.After(now) {
+		wait = next.Sub(now)
+		c.spent.last = next
+	} else {
+		c.spent.last = now
+	}
+
+	c.spent.mutex.Unlock()
+	time.Sleep(wait)
+	return nil
+}
+
+// CrawlDelay reads the Crawl-delay robots.txt asks of every crawler, or of the
+// user agent if it is named, 0 when there is none
+func (c *Client) CrawlDelay(agent string) (time.Duration, error) {
+	req, err := http.NewRequest(http.MethodGet, c.BaseURL+"robots.txt", nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := c.do(c.HTTPClient, req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Without a robots.txt nothing is asked of crawlers
+	if resp.StatusCode == http.StatusNotFound {
+		return 0, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("fetching %srobots.txt failed with %s", c.BaseURL, resp.Status)
+	}
+
+	return ParseCrawlDelay(io.LimitReader(resp.Body, 1<<20), agent)
+}
+
+// ParseCrawlDelay reads the Crawl-delay of a robots.txt for the user agent,
+// falling back to the one for every crawler, "*"
+func ParseCrawlDelay(r io.Reader, agent string) (time.Duration, error) {
+	delays := make(map[string]time.Duration)
+	agents := make([]string, 0)
+	inRules := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		value := strings.TrimSpace(line[i+1:])
+
+		switch key {
+		case "user-agent":
+			// Consecutive user agents share the rules that follow them
+			if inRules {
+				agents = agents[:0]
+				inRules = false
+			}
+			agents = append(agents, strings.ToLower(value))
+		case "crawl-delay":
+			inRules = true
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil || seconds < 0 {
+				continue
+			}
+			for _, a := range agents {
+				delays[a] = time.Duration(seconds * float64(time.Second))
+			}
+		default:
+			inRules = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	if delay, ok := delays[strings.ToLower(agent)]; ok && agent != "" {
+		return delay, nil
+	}
+	return delays["*"], nil
+}
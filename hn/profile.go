@@ -0,0 +1,141 @@
+package hn
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// A Profile is a site with the markup of HN, such as the news sites running
+// Arc, by the classes its pages are parsed with. Its fields are JSON, so
+// profiles can be kept in files.
+type Profile struct {
+	Name string
+
+	// BaseURL is where the site is, a client with the profile fetches from it
+	BaseURL string `json:",omitempty"`
+
+	// The classes of a listing, the row of a post, its rank, the link of its
+	// title or an element around it, the row under it with the score, author and
+	// age, and the link to the next page
+	Post    string
+	Rank    string
+	Title   string
+	SubText string
+	Score   string
+	Author  string
+	Age     string
+	More    string
+
+	// The classes of an item, the text of a text post, the row of a comment, its
+	// text and the indentation of its depth
+	TopText     string
+	Comment     string
+	CommentText string
+	Indent      string
+}
+
+// DefaultProfile is the markup of news.ycombinator.com
+var DefaultProfile = Profile{
+	Name:        "hn",
+	BaseURL:     BaseURL,
+	Post:        "athing",
+	Rank:        "rank",
+	Title:       "storylink",
+	SubText:     "subtext",
+	Score:       "score",
+	Author:      "hnuser",
+	Age:         "age",
+	More:        "morelink",
+	TopText:     "toptext",
+	Comment:     "comtr",
+	CommentText: "commtext",
+	Indent:      "ind",
+}
+
+var profiles = struct {
+	sync.Mutex
+	byName map[string]Profile
+}{byName: map[string]Profile{DefaultProfile.Name: DefaultProfile}}
+
+// RegisterProfile adds a profile, or replaces the one of the same name. Classes
+// it leaves empty are those of DefaultProfile.
+func RegisterProfile(p Profile) error {
+	if p.Name == "" {
+		return fmt.Errorf("a profile needs a name")
+	}
+
+	profiles.Lock()
+	defer profiles.Unlock()
+	profiles.byName[p.Name] = p.withDefaults()
+	return nil
+}
+
+// LookupProfile returns the profile registered with the name
+func LookupProfile(name string) (Profile, bool) {
+	profiles.Lock()
+	defer profiles.Unlock()
+	p, ok := profiles.byName[name]
+	return p, ok
+}
+
+// ProfileNames are the names of the registered profiles, in order
+func ProfileNames() []string {
+	profiles.Lock()
+	defer profiles.Unlock()
+
+	names := make([]string, 0, len(profiles.byName))
+	for name := range profiles.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ReadProfile reads a profile kept as JSON, classes it leaves out are those of DefaultProfile
+func ReadProfile(r io.Reader) (Profile, error) {
+	var p Profile
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&p); err != nil {
+		return Profile{}, fmt.Errorf("reading the profile: %v", err)
+	}
+	return p.withDefaults(), nil
+}
+
+// withDefaults fills in the classes left empty with those of HN, most clones only rename a few
+func (p Profile) withDefaults() Profile {
+	defaults := DefaultProfile
+	for _, class := range []struct{ value, fallback *string }{
+		{&p.Post, &defaults.Post}, {&p.Rank, &defaults.Rank}, {&p.Title, &defaults.Title},
+		{&p.SubText, &defaults.SubText}, {&p.Score, &defaults.Score}, {&p.Author, &defaults.Author},
+		{&p.Age, &defaults.Age}, {&p.More, &defaults.More}, {&p.TopText, &defaults.TopText},
+		{&p.Comment, &defaults.Comment}, {&p.CommentText, &defaults.CommentText}, {&p.Indent, &defaults.Indent},
+	} {
+		if *class.value == "" {
+			*class.value = *class.fallback
+		}
+	}
+	return p
+}
+
+// WithProfile parses pages with the classes of the profile, and fetches from
+// its BaseURL if it has one. WithBaseURL after it fetches from elsewhere.
+func WithProfile(p Profile) Option {
+	return func(c *Client) {
+		p = p.withDefaults()
+		c.Profile = &p
+		if p.BaseURL != "" {
+			WithBaseURL(p.BaseURL)(c)
+		}
+	}
+}
+
+func (c *Client) profile() *Profile {
+	if c.Profile == nil {
+		return &DefaultProfile
+	}
+	return c.Profile
+}
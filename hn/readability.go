@@ -0,0 +1,310 @@
+package hn
+
+import (
+	"golang.org/x/net/html"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// An Article is the main text of a page, without its navigation, sidebars,
+// comments and ads. Content is HTML, to be converted with ConvertText.
+type Article struct {
+	Title    string
+	Byline   string `json:",omitempty"`
+	SiteName string `json:",omitempty"`
+	URL      string
+	Content  string
+}
+
+// Read fetches a page and extracts its article
+func (e *Enricher) Read(u string) (*Article, error) {
+	node, base, err := e.fetchHTML(u)
+	if err != nil {
+		return nil, err
+	}
+
+	return ExtractArticle(node, base), nil
+}
+
+// Names of elements that are rarely the article, and of those that might be
+// despite a name that is rarely the article, e.g. "main-comments"
+var (
+	unlikelyNames = regexp.MustCompile(`(?i)banner|breadcrumb|combx|comment|community|cookie|disqus|extra|footer|header|menu|modal|newsletter|pagination|popup|promo|related|remark|replies|rss|share|shoutbox|sidebar|skyscraper|social|sponsor|subscribe`)
+	maybeNames    = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	positiveNames = regexp.MustCompile(`(?i)article|body|content|entry|hentry|main|page|post|text|blog|story`)
+	negativeNames = regexp.MustCompile(`(?i)hidden|banner|combx|comment|contact|foot|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget`)
+	whitespace    = regexp.MustCompile(`\s+`)
+)
+
+// removedElements are never part of the text of an article
+var removedElements = map[string]bool{
+	"aside": true, "button": true, "footer": true, "form": true, "header": true, "iframe": true, "input": true,
+	"nav": true, "noscript": true, "script": true, "select": true, "style": true, "svg": true, "template": true,
+	"textarea": true,
+}
+
+// blockElements start a line of their own, the whitespace around them is layout
+var blockElements = map[string]bool{
+	"article": true, "blockquote": true, "br": true, "div": true, "figcaption": true, "figure": true, "h1": true,
+	"h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "hr": true, "li": true, "main": true, "ol": true,
+	"p": true, "pre": true, "section": true, "table": true, "td": true, "th": true, "tr": true, "ul": true,
+}
+
+// ExtractArticle finds the element of a page with the most text in
+// paragraphs, in the way of Readability, along with the siblings that
+// continue it. Links and images are made absolute to base.
+func ExtractArticle(node *html.Node, base *url.URL) *Article {
+	preview := getPreview(node, base)
+	article := &Article{
+		Title:    preview.Title,
+		SiteName: preview.SiteName,
+		URL:      base.String(),
+	}
+	for _, meta := range findNode(node, func(n *html.Node) bool { return n.Type == html.ElementNode && n.Data == "meta" }) {
+		name, content := getAttribute("name", meta.Attr), getAttribute("content", meta.Attr)
+		if name != nil && content != nil && strings.EqualFold(name.Val, "author") {
+			article.Byline = strings.TrimSpace(content.Val)
+			break
+		}
+	}
+
+	body := node
+	if bodies := findNode(node, func(n *html.Node) bool { return n.Type == html.ElementNode && n.Data == "body" }); len(bodies) > 0 {
+		body = bodies[0]
+	}
+	removeClutter(body)
+
+	var b strings.Builder
+	for _, n := range articleNodes(body) {
+		resolveLinks(n, base)
+		collapseWhitespace(n)
+		html.Render(&b, n)
+	}
+	article.Content = b.String()
+
+	return article
+}
+
+// removeClutter removes the elements that are never the article, and those
+// whose class or id says they most likely are not
+func removeClutter(node *html.Node) {
+	for child := node.FirstChild; child != nil; {
+		next := child.NextSibling
+
+		if child.Type == html.CommentNode || (child.Type == html.ElementNode && (removedElements[child.Data] || isUnlikely(child))) {
+			node.RemoveChild(child)
+		} else {
+			removeClutter(child)
+		}
+
+		child = next
+	}
+}
+
+func isUnlikely(node *html.Node) bool {
+	switch node.Data {
+	case "a", "article", "body", "html", "main":
+		return false
+	}
+
+	names := className(node)
+	return unlikelyNames.MatchString(names) && !maybeNames.MatchString(names)
+}
+
+func className(node *html.Node) string {
+	var names []string
+	for _, key := range []string{"class", "id"} {
+		if attr := getAttribute(key, node.Attr); attr != nil {
+			names = append(names, attr.Val)
+		}
+	}
+	return strings.Join(names, " ")
+}
+
+// articleNodes scores paragraphs by their length and commas, each adding to
+// its parent and half to its grandparent. The element with the best score
+// after discounting links is the article, with the siblings that continue it.
+func articleNodes(body *html.Node) []*html.Node {
+	scores := make(map[*html.Node]float64)
+	candidates := make([]*html.Node, 0)
+
+	addScore := func(node *html.Node, score float64) {
+		if node == nil || node.Type != html.ElementNode {
+			return
+		}
+		if _, ok := scores[node]; !ok {
+			scores[node] = elementWeight(node)
+			candidates = append(candidates, node)
+		}
+		scores[node] += score
+	}
+
+	for _, p := range findNode(body, func(n *html.Node) bool {
+		return n.Type == html.ElementNode && (n.Data == "p" || n.Data == "pre" || n.Data == "td")
+	}) {
+		text := strings.TrimSpace(textContent(p))
+		if len(text) < 25 {
+			continue
+		}
+
+		score := 1 + float64(strings.Count(text, ",")) + math.Min(float64(len(text))/100, 3)
+		addScore(p.Parent, score)
+		if p.Parent != nil {
+			addScore(p.Parent.Parent, score/2)
+		}
+	}
+
+	var top *html.Node
+	var topScore float64
+	for _, candidate := range candidates {
+		score := scores[candidate] * (1 - linkDensity(candidate))
+		scores[candidate] = score
+		if top == nil || score > topScore {
+			top, topScore = candidate, score
+		}
+	}
+
+	// Without paragraphs, an article or main element is the best guess
+	if top == nil {
+		for _, name := range []string{"article", "main"} {
+			if found := findNode(body, func(n *html.Node) bool { return n.Type == html.ElementNode && n.Data == name }); len(found) > 0 {
+				return found[:1]
+			}
+		}
+		return []*html.Node{body}
+	}
+
+	if top.Parent == nil || top.Data == "body" {
+		return []*html.Node{top}
+	}
+
+	// Siblings with a good score of their own, or long paragraphs, continue the article
+	nodes := make([]*html.Node, 0)
+	for sibling := top.Parent.FirstChild; sibling != nil; sibling = sibling.NextSibling {
+		if sibling.Type != html.ElementNode {
+			continue
+		}
+
+		score, scored := scores[sibling]
+		if sibling == top ||
+			(scored && score >= math.Max(10, topScore*0.2)) ||
+			(sibling.Data == "p" && len(strings.TrimSpace(textContent(sibling))) > 80 && linkDensity(sibling) < 0.25) {
+			nodes = append(nodes, sibling)
+		}
+	}
+
+	return nodes
+}
+
+// elementWeight is how likely an element is to contain the article, by its name, class and id
+func elementWeight(node *html.Node) float64 {
+	var weight float64
+	switch node.Data {
+	case "article":
+		weight += 10
+	case "div":
+		weight += 5
+	case "pre", "td", "blockquote":
+		weight += 3
+	case "ol", "ul", "dl", "dd", "dt", "li", "form":
+		weight -= 3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		weight -= 5
+	}
+
+	names := className(node)
+	if positiveNames.MatchString(names) {
+		weight += 25
+	}
+	if negativeNames.MatchString(names) {
+		weight -= 25
+	}
+
+	return weight
+}
+
+// linkDensity is how much of the text of an element is links, navigation rather than prose
+func linkDensity(node *html.Node) float64 {
+	length := len(textContent(node))
+	if length == 0 {
+		return 0
+	}
+
+	var links int
+	for _, a := range findNode(node.FirstChild, func(n *html.Node) bool { return n.Type == html.ElementNode && n.Data == "a" }) {
+		links += len(textContent(a))
+	}
+
+	return float64(links) / float64(length)
+}
+
+// resolveLinks makes links and images absolute, lazily loaded images keep their source in data-src
+func resolveLinks(node *html.Node, base *url.URL) {
+	isLink := func(n *html.Node) bool {
+		return n.Type == html.ElementNode && (n.Data == "a" || n.Data == "img")
+	}
+
+	// findNode also matches the siblings after a node, which are not this part of the article
+	links := findNode(node.FirstChild, isLink)
+	if isLink(node) {
+		links = append(links, node)
+	}
+
+	for _, n := range links {
+		key := "href"
+		if n.Data == "img" {
+			key = "src"
+		}
+
+		value := getAttribute(key, n.Attr)
+		if lazy := getAttribute("data-src", n.Attr); n.Data == "img" && (value == nil || value.Val == "") && lazy != nil {
+			value = lazy
+		}
+		if value == nil {
+			continue
+		}
+		resolved := resolveURL(base, value.Val)
+
+		attrs := make([]html.Attribute, 0, len(n.Attr))
+		for _, attr := range n.Attr {
+			if attr.Key != key {
+				attrs = append(attrs, attr)
+			}
+		}
+		n.Attr = append(attrs, html.Attribute{Key: key, Val: resolved})
+	}
+}
+
+// collapseWhitespace turns the indentation and line breaks of the markup into
+// single spaces, dropping them next to blocks, except in preformatted text
+func collapseWhitespace(node *html.Node) {
+	if node.Type == html.ElementNode && node.Data == "pre" {
+		return
+	}
+
+	if node.Type == html.TextNode {
+		text := whitespace.ReplaceAllString(node.Data, " ")
+		if isBlockBoundary(node.PrevSibling, node.Parent) {
+			text = strings.TrimLeft(text, " ")
+		}
+		if isBlockBoundary(node.NextSibling, node.Parent) {
+			text = strings.TrimRight(text, " ")
+		}
+		node.Data = text
+		return
+	}
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		collapseWhitespace(child)
+	}
+}
+
+// isBlockBoundary reports whether text next to the sibling starts or ends a line
+func isBlockBoundary(sibling *html.Node, parent *html.Node) bool {
+	if sibling == nil {
+		return parent == nil || parent.Type != html.ElementNode || blockElements[parent.Data]
+	}
+	return sibling.Type == html.ElementNode && blockElements[sibling.Data]
+}
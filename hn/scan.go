@@ -0,0 +1,308 @@
+package hn
+
+import (
+	"fmt"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// A pageScanner reads a page a token at a time in a single pass, only
+// building trees of the rows it is asked for, so a page is never held as a
+// whole. The fields of a row are then parsed as they are from a whole page.
+type pageScanner struct {
+	z         *html.Tokenizer
+	u         string
+	maxTokens int
+	tokens    int
+
+	// name and attrs are of the start tag just read
+	name  string
+	attrs []html.Attribute
+}
+
+func newPageScanner(r io.Reader, u string, maxTokens int) *pageScanner {
+	return &pageScanner{z: html.NewTokenizer(r), u: u, maxTokens: maxTokens}
+}
+
+// next reads the next start tag, false at the end of the page
+func (s *pageScanner) next() (bool, error) {
+	for {
+		tt, err := s.read()
+		if err != nil || tt == html.ErrorToken {
+			return false, err
+		}
+		if tt == html.StartTagToken {
+			s.readTag()
+			return true, nil
+		}
+	}
+}
+
+// read reads a token, within the node limit, as tokens are about as many as the nodes of the page
+func (s *pageScanner) read() (html.TokenType, error) {
+	tt := s.z.Next()
+	if tt == html.ErrorToken {
+		if err := s.z.Err(); err != io.EOF {
+			return tt, err
+		}
+		return tt, nil
+	}
+
+	s.tokens++
+	if s.maxTokens > 0 && s.tokens > s.maxTokens {
+		return tt, &LimitError{URL: s.u, Limit: "node count", Max: int64(s.maxTokens)}
+	}
+
+	return tt, nil
+}
+
+func (s *pageScanner) readTag() {
+	name, more := s.z.TagName()
+	s.name = intern(name)
+	s.attrs = s.attrs[:0]
+	for more {
+		var key, val []byte
+		key, val, more = s.z.TagAttr()
+		s.attrs = append(s.attrs, html.Attribute{Key: intern(key), Val: string(val)})
+	}
+}
+
+// intern returns the names of known elements and attributes without
+// allocating, as most tokens are a handful of names over and over
+func intern(name []byte) string {
+	if a := atom.Lookup(name); a != 0 {
+		return a.String()
+	}
+	return string(name)
+}
+
+// voidElements never have children or an end tag
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true, "input": true,
+	"link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// closesParagraph are the elements that end an open paragraph, HN never closes its paragraphs
+var closesParagraph = map[string]bool{
+	"blockquote": true, "div": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"ol": true, "p": true, "pre": true, "table": true, "ul": true,
+}
+
+// tableEnds end any row or cell left open
+var tableEnds = map[string]bool{"body": true, "html": true, "table": true, "tbody": true}
+
+// subtree reads the rest of the element whose start tag was just read into a
+// tree. It is built as the parser would build the markup of HN, which closes
+// elements in order, except for paragraphs.
+func (s *pageScanner) subtree() (*html.Node, error) {
+	root := s.element()
+	open := []*html.Node{root}
+
+	for len(open) > 0 {
+		tt, err := s.read()
+		if err != nil {
+			return nil, err
+		}
+
+		top := open[len(open)-1]
+		switch tt {
+		case html.ErrorToken:
+			return root, nil
+		case html.TextToken:
+			// The parser merges text split by comments
+			if last := top.LastChild; last != nil && last.Type == html.TextNode {
+				last.Data += string(s.z.Text())
+			} else {
+				top.AppendChild(&html.Node{Type: html.TextNode, Data: string(s.z.Text())})
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			s.readTag()
+			if closesParagraph[s.name] {
+				open = closeParagraph(open)
+				top = open[len(open)-1]
+			}
+
+			node := s.element()
+			top.AppendChild(node)
+			if tt == html.StartTagToken && !voidElements[s.name] {
+				open = append(open, node)
+			}
+		case html.EndTagToken:
+			name, _ := s.z.TagName()
+			end := intern(name)
+			closed := false
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i].Data == end {
+					open, closed = open[:i], true
+					break
+				}
+			}
+
+			// The end of a table the element is in ends the element too
+			if !closed && tableEnds[end] {
+				return root, nil
+			}
+		}
+	}
+
+	return root, nil
+}
+
+func (s *pageScanner) element() *html.Node {
+	attrs := make([]html.Attribute, len(s.attrs))
+	copy(attrs, s.attrs)
+	return &html.Node{Type: html.ElementNode, Data: s.name, DataAtom: atom.Lookup([]byte(s.name)), Attr: attrs}
+}
+
+// closeParagraph closes the open paragraph, unless a cell or table is in the way
+func closeParagraph(open []*html.Node) []*html.Node {
+	for i := len(open) - 1; i > 0; i-- {
+		switch open[i].Data {
+		case "p":
+			return open[:i]
+		case "td", "th", "table", "button":
+			return open
+		}
+	}
+	return open
+}
+
+func (s *pageScanner) attr(key string) string {
+	for _, attr := range s.attrs {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func (s *pageScanner) hasClass(class string) bool {
+	return contains(strings.Fields(s.attr("class")), class)
+}
+
+// ScanPosts parses the posts of a listing as ParsePosts does, a token at a
+// time, for pages too large to hold as a whole
+func ScanPosts(r io.Reader) (Posts, error) {
+	posts, _, err := DefaultProfile.scanPosts(newPageScanner(r, "", 0), discardLogger)
+	return posts, err
+}
+
+// scanPosts parses the rows of a listing, each post row with the row after
+// it, and returns the link to the next page of the listing
+func (p *Profile) scanPosts(s *pageScanner, logger *slog.Logger) (Posts, string, error) {
+	posts := make(Posts, 0)
+	var more string
+	var postNode *html.Node
+	var postID string
+	rows := 0
+
+	for {
+		ok, err := s.next()
+		if err != nil {
+			return nil, "", err
+		}
+		if !ok {
+			break
+		}
+
+		switch {
+		case s.name == "tr" && postNode != nil:
+			nextRow, err := s.subtree()
+			if err != nil {
+				return nil, "", err
+			}
+
+			post, ok, err := p.getPost(postNode, nextRow.FirstChild, logger)
+			if err != nil {
+				return nil, "", err
+			}
+			if ok {
+				posts = append(posts, post)
+			}
+			postNode = nil
+		case s.name == "tr" && s.attr("class") == p.Post:
+			rows++
+			postID = s.attr("id")
+			if postNode, err = s.subtree(); err != nil {
+				return nil, "", err
+			}
+		case s.name == "a" && s.attr("class") == p.More:
+			more = s.attr("href")
+		}
+	}
+
+	// A post row at the end of the page has no row after it, so it is not listed
+	if postNode != nil {
+		logger.Debug("row skipped, it has no subtext row", "id", postID)
+	}
+
+	logger.Debug("rows matched", "selector", "."+p.Post, "count", rows)
+	return posts, more, nil
+}
+
+// ScanItem parses an item page as ParseItem does, a token at a time, for
+// stories with so many comments the page is too large to hold as a whole
+func ScanItem(r io.Reader, id int) (*Item, error) {
+	return DefaultProfile.scanItem(newPageScanner(r, "", 0), id)
+}
+
+func (p *Profile) scanItem(s *pageScanner, id int) (*Item, error) {
+	var itemNode, subTextRow *html.Node
+	var text string
+	tree := p.newCommentTree()
+	itemID := strconv.Itoa(id)
+
+	for {
+		ok, err := s.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+
+		switch {
+		case itemNode == nil && s.attr("id") == itemID:
+			if itemNode, err = s.subtree(); err != nil {
+				return nil, err
+			}
+		case itemNode != nil && subTextRow == nil && s.name == itemNode.Data:
+			if subTextRow, err = s.subtree(); err != nil {
+				return nil, err
+			}
+		case s.attr("class") == p.TopText && text == "":
+			node, err := s.subtree()
+			if err != nil {
+				return nil, err
+			}
+			if text, err = innerHTML(node); err != nil {
+				return nil, err
+			}
+		case s.name == "tr" && s.hasClass(p.Comment):
+			row, err := s.subtree()
+			if err != nil {
+				return nil, err
+			}
+			if err := tree.add(row); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if itemNode == nil {
+		return nil, fmt.Errorf("item %d was not found", id)
+	}
+
+	item, err := p.getItemPost(itemNode, subTextRow, id)
+	if err != nil {
+		return nil, err
+	}
+	item.Text = text
+	item.Replies = tree.replies
+
+	return item, nil
+}
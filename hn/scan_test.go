@@ -0,0 +1,141 @@
+package hn
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testdata/front.html is a front page made up in the markup of HN, with
+// stories such as "Story number 3" and a job ad at rank 7. It is not a saved
+// page of news.ycombinator.com.
+func readFrontPage(t testing.TB) []byte {
+	page, err := os.ReadFile("testdata/front.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return page
+}
+
+func TestScanPostsMatchesParsePosts(t *testing.T) {
+	page := readFrontPage(t)
+
+	parsed, err := ParsePosts(bytes.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scanned, err := ScanPosts(bytes.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(parsed) != PostsPerPage {
+		t.Errorf("parsed %d posts, want %d", len(parsed), PostsPerPage)
+	}
+
+	if !reflect.DeepEqual(parsed, scanned) {
+		t.Errorf("scanned posts differ from parsed posts\nparsed:  %+v\nscanned: %+v", parsed, scanned)
+	}
+}
+
+func BenchmarkParseListing(b *testing.B) {
+	page := readFrontPage(b)
+	b.SetBytes(int64(len(page)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := ParsePosts(bytes.NewReader(page)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkScanListing(b *testing.B) {
+	page := readFrontPage(b)
+	b.SetBytes(int64(len(page)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := ScanPosts(bytes.NewReader(page)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+const (
+	testThreadItem = `<html><body><table id="hnmain"><tr><td><table class="fatitem">` +
+		`<tr class="athing" id="1"><td class="title"><a href="https://example.com/" class="storylink">A large thread</a></td></tr>` +
+		`<tr><td colspan="2"></td><td class="subtext"><span class="score" id="score_1">900 points</span> by <a href="user?id=someone" class="hnuser">someone</a> ` +
+		`<span class="age" title="2020-01-01T00:00:00"><a href="item?id=1">1 hour ago</a></span> | <a href="item?id=1">%d&nbsp;comments</a>` + "\n" +
+		`</td></tr></table><table class="comment-tree">`
+	testThreadComment = `<tr class="athing comtr" id="%[1]d"><td><table border="0"><tr>` +
+		`<td class="ind"><img src="s.gif" height="1" width="%[2]d"></td><td valign="top" class="votelinks"></td><td class="default">` +
+		`<div style="margin-top:2px; margin-bottom:-10px;"><span class="comhead"><a href="user?id=user%[3]d" class="hnuser">user%[3]d</a> ` +
+		`<span class="age" title="2020-01-01T00:00:00"><a href="item?id=%[1]d">1 hour ago</a></span> <span id="unv_%[1]d"></span></span></div><br>` +
+		`<div class="comment"><span class="commtext c00">Comment %[1]d, with <i>some</i> text.<p>And a <a href="https://example.com/%[1]d" rel="nofollow">link</a>.` +
+		`<div class="reply"><p><font size="1"><u><a href="reply?id=%[1]d&amp;goto=item">reply</a></u></font></div></span></div>` +
+		`</td></tr></table></td></tr>`
+)
+
+// testThread makes up an item page of comments, each a reply to the one before
+// it up to 5 deep, as there is no large thread saved in testdata
+func testThread(comments int) []byte {
+	var page strings.Builder
+	fmt.Fprintf(&page, testThreadItem, comments)
+	for i := 0; i < comments; i++ {
+		fmt.Fprintf(&page, testThreadComment, i+2, i%5*40, i%97)
+	}
+	page.WriteString(`</table></td></tr></table></body></html>`)
+	return []byte(page.String())
+}
+
+func TestScanItemMatchesParseItem(t *testing.T) {
+	page := testThread(500)
+
+	parsed, err := ParseItem(bytes.NewReader(page), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scanned, err := ScanItem(bytes.NewReader(page), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(parsed.Replies) != 100 {
+		t.Errorf("parsed %d top level comments, want 100", len(parsed.Replies))
+	}
+
+	if !reflect.DeepEqual(parsed, scanned) {
+		t.Error("scanned item differs from parsed item")
+	}
+}
+
+// A thread of 10000 comments is a page of about 7 MB
+func BenchmarkParseItem(b *testing.B) {
+	page := testThread(10000)
+	b.SetBytes(int64(len(page)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseItem(bytes.NewReader(page), 1); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkScanItem(b *testing.B) {
+	page := testThread(10000)
+	b.SetBytes(int64(len(page)))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := ScanItem(bytes.NewReader(page), 1); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
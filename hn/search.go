@@ -0,0 +1,142 @@
+package hn
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SearchURL is the search API of HN, run by Algolia, which has every story
+// ever submitted rather than only those still listed
+const SearchURL = "https://hn.algolia.com/api/v1/"
+
+// A Search finds submissions with the search API of HN
+type Search struct {
+	HTTPClient *http.Client
+	URL        string
+}
+
+// NewSearch searches with the API of HN, waiting up to 10 seconds for it
+func NewSearch() *Search {
+	return &Search{
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		URL:        SearchURL,
+	}
+}
+
+type searchHit struct {
+	ObjectID    string `json:"objectID"`
+	Title       string `json:"title"`
+	URL         string `json:"url"`
+	Author      string `json:"author"`
+	Points      int    `json:"points"`
+	NumComments int    `json:"num_comments"`
+	CreatedAt   int64  `json:"created_at_i"`
+}
+
+// Submissions finds the stories submitted with a url, or a variant of it
+// that NormalizeURL makes the same, newest first
+func (s *Search) Submissions(u string) (Posts, error) {
+	normalized := NormalizeURL(u)
+	if normalized == "" {
+		return nil, fmt.Errorf("%q is not a url", u)
+	}
+
+	// The search matches words, so the url without its query finds every variant, which are then narrowed down
+	query, _, _ := strings.Cut(normalized, "?")
+	values := url.Values{
+		"query":                        {query},
+		"restrictSearchableAttributes": {"url"},
+		"tags":                         {"story"},
+		"hitsPerPage":                  {"100"},
+	}
+	searchURL := strings.TrimSuffix(s.URL, "/") + "/search_by_date?" + values.Encode()
+
+	resp, err := s.HTTPClient.Get(searchURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{URL: searchURL, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	var result struct {
+		Hits []searchHit `json:"hits"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 16<<20)).Decode(&result); err != nil {
+		return nil, &ParseError{URL: searchURL, Err: fmt.Errorf("reading the search: %v", err)}
+	}
+
+	posts := make(Posts, 0)
+	for _, hit := range result.Hits {
+		if NormalizeURL(hit.URL) != normalized {
+			continue
+		}
+
+		id, err := strconv.Atoi(hit.ObjectID)
+		if err != nil {
+			continue
+		}
+
+		posts = append(posts, Post{
+			ID:       id,
+			Title:    hit.Title,
+			URL:      hit.URL,
+			Author:   hit.Author,
+			Points:   hit.Points,
+			Comments: hit.NumComments,
+			Time:     time.Unix(hit.CreatedAt, 0).UTC(),
+		})
+	}
+
+	sort.SliceStable(posts, func(i, j int) bool { return posts[i].Time.After(posts[j].Time) })
+	return posts, nil
+}
+
+// trackingParams are added to links by whoever shared them, not part of the page
+var trackingParams = map[string]bool{"fbclid": true, "gclid": true, "ref": true, "ref_src": true, "source": true}
+
+// NormalizeURL reduces a url to what tells pages apart, so variants of it
+// compare equal. The scheme, www and m subdomains, default ports, trailing
+// slashes, fragments and tracking parameters are dropped, the rest of the
+// query is sorted. A url without a host is "".
+func NormalizeURL(u string) string {
+	if !strings.Contains(u, "://") {
+		u = "http://" + u
+	}
+
+	parsed, err := url.Parse(strings.TrimSpace(u))
+	if err != nil || parsed.Hostname() == "" {
+		return ""
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+	for _, prefix := range []string{"www.", "m."} {
+		host = strings.TrimPrefix(host, prefix)
+	}
+	if port := parsed.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+
+	query := parsed.Query()
+	for key := range query {
+		if strings.HasPrefix(key, "utm_") || trackingParams[key] {
+			query.Del(key)
+		}
+	}
+
+	normalized := host + strings.TrimRight(parsed.EscapedPath(), "/")
+	if len(query) > 0 {
+		// Encode sorts by key
+		normalized += "?" + query.Encode()
+	}
+	return normalized
+}
@@ -0,0 +1,22 @@
+package hn
+
+import "time"
+
+// Stories are rarely on the front page after a day, fewer still near the top,
+// unless the moderators re-upped them from the second-chance pool
+const (
+	secondChanceAge     = 12 * time.Hour
+	secondChancePerRank = 30 * time.Minute
+)
+
+// IsSecondChance guesses whether a post on the front page is there from the
+// second-chance pool, as it is older at the time now than posts usually are
+// at its rank. Posts without a time are never.
+func IsSecondChance(post Post, now time.Time) bool {
+	if post.Time.IsZero() || post.Rank < 1 {
+		return false
+	}
+
+	threshold := secondChanceAge + time.Duration(post.Rank-1)*secondChancePerRank
+	return now.Sub(post.Time) > threshold
+}
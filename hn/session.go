@@ -0,0 +1,219 @@
+package hn
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/net/html"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// sessionCookie is the cookie HN keeps a login in, its value is the user name and a token
+const sessionCookie = "user"
+
+// Login logs in and sets the session of the client, which can be kept to
+// make requests as the user later without logging in again
+func (c *Client) Login(user string, password string) error {
+	form := url.Values{"acct": {user}, "pw": {password}, "goto": {"news"}}
+	resp, err := c.post("login", form)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == sessionCookie && cookie.Value != "" {
+			c.Session = cookie.Value
+			return nil
+		}
+	}
+
+	return c.rejected("login", resp)
+}
+
+// User is the name of the logged in user, from the session
+func (c *Client) User() string {
+	return strings.SplitN(c.Session, "&", 2)[0]
+}
+
+// Submit submits a story, a link or text such as an Ask HN, and returns it as
+// found on the submissions of the user, to be sure it was accepted
+func (c *Client) Submit(title string, link string, text string) (*Post, error) {
+	if c.Session == "" {
+		return nil, errors.New("submitting requires logging in")
+	}
+
+	form, err := c.fetchForm(c.BaseURL+"submit", "r")
+	if err != nil {
+		return nil, err
+	}
+	form.Set("title", title)
+	form.Set("url", link)
+	form.Set("text", text)
+
+	resp, err := c.post("r", form)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if !isRedirect(resp) {
+		return nil, c.rejected("submission", resp)
+	}
+	c.invalidate(c.BaseURL+SectionNew, c.BaseURL+"submitted?id="+url.QueryEscape(c.User()))
+
+	submissions, err := c.FetchSubmissions(c.User())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, post := range submissions {
+		if post.Title == title {
+			return &post, nil
+		}
+	}
+
+	return nil, fmt.Errorf("the submission was sent but is not one of the submissions of %s, it may have been flagged as a duplicate", c.User())
+}
+
+// Reply replies to a story or comment and returns the reply as found on the
+// comments of the user, to be sure it was accepted
+func (c *Client) Reply(parent int, text string) (*Comment, error) {
+	if c.Session == "" {
+		return nil, errors.New("replying requires logging in")
+	}
+
+	// The reply form has the hmac that has to be sent with it
+	id := strconv.Itoa(parent)
+	form, err := c.fetchForm(c.BaseURL+"reply?id="+id+"&goto="+url.QueryEscape("item?id="+id), "comment")
+	if err != nil {
+		return nil, err
+	}
+	form.Set("text", text)
+
+	resp, err := c.post("comment", form)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if !isRedirect(resp) {
+		return nil, c.rejected("reply", resp)
+	}
+	c.invalidate(c.ItemURL(parent), c.BaseURL+"threads?id="+url.QueryEscape(c.User()))
+
+	comments, err := c.FetchUserComments(c.User())
+	if err != nil {
+		return nil, err
+	}
+
+	want := strings.Join(strings.Fields(text), " ")
+	for _, comment := range comments {
+		if strings.Join(strings.Fields(PlainText(comment.Text)), " ") == want {
+			return comment, nil
+		}
+	}
+
+	return nil, fmt.Errorf("the reply was sent but is not one of the comments of %s", c.User())
+}
+
+// invalidate removes the cached pages a submission or reply changed, for the runs that read them
+func (c *Client) invalidate(prefixes ...string) {
+	if c.Cache == nil {
+		return
+	}
+	for _, prefix := range prefixes {
+		c.Cache.Invalidate(prefix)
+	}
+}
+
+// fetchForm fetches a page with a form and returns its hidden fields, such as fnid and hmac
+func (c *Client) fetchForm(u string, action string) (url.Values, error) {
+	var node *html.Node
+	err := c.fetchPage(u, func(page *html.Node) error {
+		node = page
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	forms := findNode(node, func(n *html.Node) bool {
+		if n.Type != html.ElementNode || n.Data != "form" {
+			return false
+		}
+		attr := getAttribute("action", n.Attr)
+		return attr != nil && strings.TrimPrefix(attr.Val, "/") == action
+	})
+
+	// Without a session HN shows the login form instead
+	if len(forms) == 0 {
+		return nil, fmt.Errorf("%s does not have the %s form, is the session still valid?", u, action)
+	}
+
+	values := url.Values{}
+	for _, input := range findNode(forms[0].FirstChild, func(n *html.Node) bool {
+		return n.Type == html.ElementNode && n.Data == "input" && hasAttribute("type", "hidden", n.Attr)
+	}) {
+		name := getAttribute("name", input.Attr)
+		value := getAttribute("value", input.Attr)
+		if name != nil && value != nil {
+			values.Set(name.Val, value.Val)
+		}
+	}
+
+	return values, nil
+}
+
+// post sends a form, without following the redirect that means it was accepted
+func (c *Client) post(path string, form url.Values) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, c.BaseURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	httpClient := *c.HTTPClient
+	httpClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return c.do(&httpClient, req)
+}
+
+func isRedirect(resp *http.Response) bool {
+	return resp.StatusCode >= 300 && resp.StatusCode < 400
+}
+
+// rejected reads why HN did not accept a form, such as "Bad login." or
+// "You're posting too fast.", from the page it returned instead
+func (c *Client) rejected(what string, resp *http.Response) error {
+	if resp.StatusCode != http.StatusOK && !isRedirect(resp) {
+		return fmt.Errorf("%s failed with %s", what, resp.Status)
+	}
+
+	u := resp.Request.URL.String()
+	limited, err := c.limitBody(u, resp.Body, resp.ContentLength)
+	if err != nil {
+		return fmt.Errorf("%s was not accepted", what)
+	}
+
+	node, err := c.parsePage(u, limited)
+	if err != nil {
+		return fmt.Errorf("%s was not accepted", what)
+	}
+
+	body := findNode(node, func(n *html.Node) bool { return n.Type == html.ElementNode && n.Data == "body" })
+	if len(body) == 0 {
+		return fmt.Errorf("%s was not accepted", what)
+	}
+
+	message := strings.Join(strings.Fields(textContent(body[0])), " ")
+	if runes := []rune(message); len(runes) > 200 {
+		message = string(runes[:200]) + "..."
+	}
+
+	return fmt.Errorf("%s was not accepted: %s", what, message)
+}
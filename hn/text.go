@@ -0,0 +1,233 @@
+package hn
+
+import (
+	"fmt"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"regexp"
+	"strings"
+)
+
+// Text formats for story and comment text, which HN serves as fragments of HTML
+const (
+	TextHTML     = "html"
+	TextMarkdown = "markdown"
+	TextPlain    = "plain"
+)
+
+// ValidTextFormat checks the format is one of the text formats
+func ValidTextFormat(format string) error {
+	switch format {
+	case TextHTML, TextMarkdown, TextPlain:
+		return nil
+	}
+
+	return fmt.Errorf("unknown text format %q, must be plain, markdown or html", format)
+}
+
+// FormatItemText converts the text of the item and every comment in place
+func FormatItemText(item *Item, format string) {
+	item.Text = ConvertText(item.Text, format)
+	FormatCommentText(item.Replies, format)
+}
+
+// FormatCommentText converts the text of the comments and their replies in place
+func FormatCommentText(comments []*Comment, format string) {
+	for _, comment := range comments {
+		comment.Text = ConvertText(comment.Text, format)
+		FormatCommentText(comment.Replies, format)
+	}
+}
+
+// ConvertText converts a fragment of HTML to the text format
+func ConvertText(fragment string, format string) string {
+	switch format {
+	case TextMarkdown:
+		return renderText(fragment, true)
+	case TextPlain:
+		return renderText(fragment, false)
+	}
+	return fragment
+}
+
+// PlainText strips the markup from a fragment of HTML, such as a comment,
+// so it can be matched without matching tags and attributes
+func PlainText(fragment string) string {
+	return renderText(fragment, false)
+}
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// renderText renders the markup HN allows in text, paragraphs, italics, links
+// and code blocks, as Markdown or as plain text
+func renderText(fragment string, markdown bool) string {
+	nodes, err := html.ParseFragment(strings.NewReader(fragment), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "div",
+		DataAtom: atom.Div,
+	})
+	if err != nil {
+		return fragment
+	}
+
+	var b strings.Builder
+	for _, node := range nodes {
+		renderNode(&b, node, markdown)
+	}
+
+	return strings.TrimSpace(blankLines.ReplaceAllString(b.String(), "\n\n"))
+}
+
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`")
+
+func renderNode(b *strings.Builder, node *html.Node, markdown bool) {
+	switch node.Type {
+	case html.TextNode:
+		if markdown {
+			b.WriteString(markdownEscaper.Replace(node.Data))
+		} else {
+			b.WriteString(node.Data)
+		}
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	children := func() {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			renderNode(b, child, markdown)
+		}
+	}
+
+	switch node.Data {
+	case "p":
+		b.WriteString("\n\n")
+		children()
+	case "br":
+		b.WriteString("\n")
+	case "i", "em":
+		wrap(b, "*", children, markdown)
+	case "b", "strong":
+		wrap(b, "**", children, markdown)
+	case "code":
+		// Code outside of a block is inline
+		wrap(b, "`", func() { b.WriteString(textContent(node)) }, markdown)
+	case "pre":
+		code := strings.TrimRight(textContent(node), "\n")
+		if markdown {
+			b.WriteString("\n\n```\n" + code + "\n```\n\n")
+		} else {
+			b.WriteString("\n\n" + code + "\n\n")
+		}
+	case "a":
+		renderLink(b, node, markdown)
+
+	// HN text never has these, articles extracted from pages do
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		b.WriteString("\n\n")
+		if markdown {
+			b.WriteString(strings.Repeat("#", int(node.Data[1]-'0')) + " ")
+		}
+		children()
+		b.WriteString("\n\n")
+	case "ul", "ol":
+		b.WriteString("\n\n")
+		n := 0
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode || child.Data != "li" {
+				renderNode(b, child, markdown)
+				continue
+			}
+
+			n++
+			if node.Data == "ol" {
+				fmt.Fprintf(b, "\n%d. ", n)
+			} else {
+				b.WriteString("\n- ")
+			}
+			for grandchild := child.FirstChild; grandchild != nil; grandchild = grandchild.NextSibling {
+				renderNode(b, grandchild, markdown)
+			}
+		}
+		b.WriteString("\n\n")
+	case "li":
+		b.WriteString("\n- ")
+		children()
+	case "blockquote":
+		var quote strings.Builder
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			renderNode(&quote, child, markdown)
+		}
+		b.WriteString("\n\n")
+		for _, line := range strings.Split(strings.TrimSpace(blankLines.ReplaceAllString(quote.String(), "\n\n")), "\n") {
+			b.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+		}
+		b.WriteString("\n")
+	case "img":
+		alt, src := getAttribute("alt", node.Attr), getAttribute("src", node.Attr)
+		if markdown && src != nil {
+			text := ""
+			if alt != nil {
+				text = markdownEscaper.Replace(alt.Val)
+			}
+			b.WriteString("![" + text + "](" + src.Val + ")")
+		} else if alt != nil && alt.Val != "" {
+			b.WriteString("[" + alt.Val + "]")
+		}
+	case "hr":
+		b.WriteString("\n\n---\n\n")
+	case "div", "section", "article", "main", "figure", "figcaption", "table", "tr":
+		b.WriteString("\n\n")
+		children()
+		b.WriteString("\n\n")
+	case "td", "th":
+		children()
+		b.WriteString(" ")
+	default:
+		children()
+	}
+}
+
+func wrap(b *strings.Builder, marker string, children func(), markdown bool) {
+	if markdown {
+		b.WriteString(marker)
+	}
+	children()
+	if markdown {
+		b.WriteString(marker)
+	}
+}
+
+// renderLink uses the href when the text is the link itself, which HN
+// abbreviates, e.g. "https://example.com/a/very/lo..."
+func renderLink(b *strings.Builder, node *html.Node, markdown bool) {
+	text := textContent(node)
+	href := ""
+	if attr := getAttribute("href", node.Attr); attr != nil {
+		href = attr.Val
+	}
+
+	abbreviated := href != "" && strings.HasPrefix(href, strings.TrimSuffix(text, "..."))
+
+	switch {
+	case href == "":
+		b.WriteString(text)
+	case abbreviated && markdown:
+		b.WriteString("<" + href + ">")
+	case abbreviated:
+		b.WriteString(href)
+	case markdown:
+		b.WriteString("[" + markdownEscaper.Replace(text) + "](" + href + ")")
+	default:
+		b.WriteString(text + " (" + href + ")")
+	}
+}
+
+func textContent(node *html.Node) string {
+	var b strings.Builder
+	for _, text := range findNode(node.FirstChild, func(n *html.Node) bool { return n.Type == html.TextNode }) {
+		b.WriteString(text.Data)
+	}
+	return b.String()
+}
@@ -0,0 +1,92 @@
+package hn
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// A TopComment is the first top level comment of a story, which HN ranks
+// highest, with its text as plain text cut short at TopCommentLength
+type TopComment struct {
+	ID     int    `json:",omitempty"`
+	Author string `json:",omitempty"`
+	Text   string `json:",omitempty"`
+	Error  string `json:",omitempty"`
+}
+
+// TopCommentLength is how many characters of the text of a top comment are kept
+const TopCommentLength = 300
+
+// FetchTopComments fetches the item page of every post with comments, at most
+// parallel at once, and sets its TopComment. An item that fails to fetch sets
+// the Error of its top comment rather than failing the rest.
+func (c *Client) FetchTopComments(posts Posts, parallel int) {
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	slots := make(chan bool, parallel)
+	var wait sync.WaitGroup
+
+	for i := range posts {
+		// Advertisements and stories without comments have no item page worth fetching
+		if posts[i].ID == 0 || posts[i].Comments < 1 {
+			continue
+		}
+
+		wait.Add(1)
+		slots <- true
+		go func(post *Post) {
+			defer wait.Done()
+			defer func() { <-slots }()
+
+			item, err := c.FetchItem(post.ID)
+			if err != nil {
+				post.TopComment = &TopComment{Error: err.Error()}
+				return
+			}
+			post.TopComment = getTopComment(item.Replies)
+		}(&posts[i])
+	}
+
+	wait.Wait()
+}
+
+// getTopComment is the first top level comment that is neither deleted nor dead
+func getTopComment(comments []*Comment) *TopComment {
+	for _, comment := range comments {
+		if comment.Author == "" || comment.Text == "" {
+			continue
+		}
+
+		text := strings.Join(strings.Fields(ConvertText(comment.Text, TextPlain)), " ")
+		return &TopComment{ID: comment.ID, Author: comment.Author, Text: cutText(text, TopCommentLength)}
+	}
+
+	return nil
+}
+
+// cutText cuts text to at most length characters, at the end of a word when
+// there is one, ending it with an ellipsis
+func cutText(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+
+	cut := runes[:length-1]
+	if i := lastSpace(cut); i > length/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRightFunc(string(cut), unicode.IsSpace) + "…"
+}
+
+func lastSpace(runes []rune) int {
+	for i := len(runes) - 1; i >= 0; i-- {
+		if unicode.IsSpace(runes[i]) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,145 @@
+package hn
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/net/html"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// A User is the profile of an account
+type User struct {
+	ID      string
+	Created time.Time
+	Karma   int
+	About   string
+}
+
+// FetchUser fetches the profile of a user
+func (c *Client) FetchUser(name string) (*User, error) {
+	var user *User
+	err := c.fetchPage(c.UserURL(name), func(node *html.Node) (err error) {
+		user, err = getUser(node, name)
+		return err
+	})
+	return user, err
+}
+
+// FetchSubmissions fetches the most recent stories a user submitted, the first page of them
+func (c *Client) FetchSubmissions(name string) (Posts, error) {
+	u := c.BaseURL + "submitted?id=" + url.QueryEscape(name)
+
+	var posts Posts
+	err := c.fetchPage(u, func(node *html.Node) (err error) {
+		posts, err = c.profile().getPosts(node, c.logger().With("url", u))
+		return err
+	})
+	return posts, err
+}
+
+// FetchUserComments fetches the most recent comments of a user, the first page
+// of them, without the replies of others
+func (c *Client) FetchUserComments(name string) ([]*Comment, error) {
+	var comments []*Comment
+	err := c.fetchPage(c.BaseURL+"threads?id="+url.QueryEscape(name), func(node *html.Node) (err error) {
+		comments, err = c.profile().getUserComments(node, name)
+		return err
+	})
+	return comments, err
+}
+
+// UserURL is the profile page of a user
+func (c *Client) UserURL(name string) string {
+	return c.BaseURL + "user?id=" + url.QueryEscape(name)
+}
+
+// ParseUser parses a profile page
+func ParseUser(r io.Reader, name string) (*User, error) {
+	node, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return getUser(node, name)
+}
+
+// getUser reads the rows of the profile, each a label such as "karma:" and a value
+func getUser(node *html.Node, name string) (*User, error) {
+	values := make(map[string]*html.Node)
+	for _, label := range findNode(node, func(n *html.Node) bool {
+		if n.Type != html.ElementNode || n.Data != "td" || n.FirstChild == nil || n.FirstChild != n.LastChild {
+			return false
+		}
+		return n.FirstChild.Type == html.TextNode && strings.HasSuffix(strings.TrimSpace(n.FirstChild.Data), ":")
+	}) {
+		if value := nextElementSibling(label); value != nil {
+			values[strings.TrimSuffix(strings.TrimSpace(label.FirstChild.Data), ":")] = value
+		}
+	}
+
+	// Unknown users get a page saying "No such user."
+	if values["user"] == nil {
+		return nil, fmt.Errorf("user %q was not found", name)
+	}
+
+	user := &User{ID: strings.TrimSpace(textContent(values["user"]))}
+
+	karma, ok := values["karma"]
+	if !ok {
+		return nil, errors.New("user karma was not found")
+	}
+
+	var err error
+	user.Karma, err = parseCount(textContent(karma))
+	if err != nil {
+		return nil, errors.New("user karma failed to convert to integer")
+	}
+
+	// The created date links to the front page of that day, e.g. front?day=2006-10-09
+	if created, ok := values["created"]; ok {
+		for _, link := range findNode(created.FirstChild, func(n *html.Node) bool { return n.Type == html.ElementNode && n.Data == "a" }) {
+			href := getAttribute("href", link.Attr)
+			if href == nil {
+				continue
+			}
+
+			u, err := url.Parse(href.Val)
+			if err != nil {
+				continue
+			}
+
+			if t, err := time.Parse("2006-01-02", u.Query().Get("day")); err == nil {
+				user.Created = t
+			}
+		}
+	}
+
+	if about, ok := values["about"]; ok {
+		user.About, err = innerHTML(about)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+// getUserComments reads the comments of a threads page, leaving out the replies to them
+func (p *Profile) getUserComments(node *html.Node, name string) ([]*Comment, error) {
+	comments := make([]*Comment, 0)
+	for _, row := range findNode(node, findByClassName(p.Comment)) {
+		comment, _, err := p.getComment(row)
+		if err != nil {
+			return nil, err
+		}
+
+		if comment.Author == name {
+			comments = append(comments, comment)
+		}
+	}
+
+	return comments, nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hn/hn"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+func runItem(args []string) error {
+	var withComments bool
+	var stdin bool
+	var parallel int
+	var textFormat string
+
+	flags := flag.NewFlagSet("item", flag.ContinueOnError)
+	flags.BoolVar(&withComments, "comments", false, "Include the comment tree")
+	flags.BoolVar(&stdin, "stdin", false, "Read newline separated ids or urls from stdin, printing a JSON object per line")
+	flags.IntVar(&parallel, "parallel", 4, "How many items to fetch at once with -stdin")
+	flags.StringVar(&textFormat, "text-format", hn.TextMarkdown, "Format of the story and comment text, plain, markdown or html")
+
+	clientOptions := addClientFlags(flags)
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if err := clientOptions.apply(); err != nil {
+		return err
+	}
+
+	if err := hn.ValidTextFormat(textFormat); err != nil {
+		return err
+	}
+
+	if stdin {
+		if parallel < 1 {
+			return errors.New("parallel must be a positive integer")
+		}
+		return fetchItems(os.Stdin, os.Stdout, parallel, withComments, textFormat)
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("usage: hn item [-comments] <id|url> or hn item -stdin")
+	}
+
+	id, err := hn.ParseItemID(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	item, err := client.FetchItem(id)
+	if err != nil {
+		return err
+	}
+
+	if !withComments {
+		item.Replies = nil
+	}
+	hn.FormatItemText(item, textFormat)
+
+	response, err := json.MarshalIndent(item, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(os.Stdout, string(response))
+	return err
+}
+
+// fetchItems fetches the ids or urls read from r, at most parallel at a time,
+// writing each item as a line of JSON in the order they were read, as soon as
+// the items before it are written
+func fetchItems(r io.Reader, w io.Writer, parallel int, withComments bool, textFormat string) error {
+	var mutex sync.Mutex
+	var wait sync.WaitGroup
+	encoder := json.NewEncoder(w)
+	semaphore := make(chan bool, parallel)
+	failed, total := 0, 0
+
+	// fetched holds the items fetched out of order, nil for failures, until
+	// every item read before them is written
+	fetched := make(map[int]*hn.Item)
+	next := 0
+	write := func(index int, item *hn.Item) {
+		fetched[index] = item
+		for {
+			item, ok := fetched[next]
+			if !ok {
+				return
+			}
+			delete(fetched, next)
+			next++
+
+			if item == nil {
+				continue
+			}
+
+			if err := encoder.Encode(item); err != nil {
+				slog.Error("writing item failed", "id", item.ID, "error", err)
+				failed++
+			}
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	for index := 0; scanner.Scan(); {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		total++
+
+		id, err := hn.ParseItemID(line)
+		if err != nil {
+			slog.Error("not an item", "line", line, "error", err)
+
+			mutex.Lock()
+			failed++
+			write(index, nil)
+			mutex.Unlock()
+
+			index++
+			continue
+		}
+
+		semaphore <- true
+		wait.Add(1)
+		go func(index int, id int) {
+			defer wait.Done()
+			defer func() { <-semaphore }()
+
+			item, err := client.FetchItem(id)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			// A single failure should not lose the rest of the batch
+			if err != nil {
+				slog.Error("fetching item failed", "id", id, "error", err)
+				failed++
+				write(index, nil)
+				return
+			}
+
+			if !withComments {
+				item.Replies = nil
+			}
+			hn.FormatItemText(item, textFormat)
+
+			write(index, item)
+		}(index, id)
+		index++
+	}
+	wait.Wait()
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d items failed", failed, total)
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hn/hn"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testItemPage = `<html><body><table id="hnmain"><tr><td><table class="fatitem">` +
+	`<tr class="athing" id="%[1]d"><td class="title"><a href="https://example.com/%[1]d" class="storylink">Story %[1]d</a></td></tr>` +
+	`<tr><td colspan="2"></td><td class="subtext"><span class="score" id="score_%[1]d">1 point</span> by <a href="user?id=someone" class="hnuser">someone</a> ` +
+	`<span class="age" title="2020-01-01T00:00:00"><a href="item?id=%[1]d">1 hour ago</a></span> | <a href="item?id=%[1]d">0&nbsp;comments</a>` + "\n" +
+	`</td></tr>` +
+	`</table></td></tr></table></body></html>`
+
+// newTestItemServer serves item pages, taking delays[id] to answer and
+// failing for the ids in failures
+func newTestItemServer(delays map[int]time.Duration, failures map[int]bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil || failures[id] {
+			http.NotFound(w, r)
+			return
+		}
+
+		time.Sleep(delays[id])
+		fmt.Fprintf(w, testItemPage, id)
+	}))
+}
+
+func TestFetchItemsOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		parallel int
+		delays   map[int]time.Duration
+		failures map[int]bool
+		want     []int
+		wantErr  string
+	}{
+		{
+			name:     "later items done first",
+			input:    "1\n2\n3\n4\n",
+			parallel: 4,
+			delays:   map[int]time.Duration{1: 60 * time.Millisecond, 2: 40 * time.Millisecond, 3: 20 * time.Millisecond},
+			want:     []int{1, 2, 3, 4},
+		},
+		{
+			name:     "one at a time",
+			input:    "4\n3\n2\n1\n",
+			parallel: 1,
+			want:     []int{4, 3, 2, 1},
+		},
+		{
+			name:     "failures are skipped",
+			input:    "1\n2\n3\n4\n",
+			parallel: 4,
+			delays:   map[int]time.Duration{1: 40 * time.Millisecond, 4: 20 * time.Millisecond},
+			failures: map[int]bool{2: true},
+			want:     []int{1, 3, 4},
+			wantErr:  "1 of 4 items failed",
+		},
+		{
+			name:     "lines that are not items are skipped",
+			input:    "5\nnot an item\n\n6\n",
+			parallel: 2,
+			delays:   map[int]time.Duration{5: 40 * time.Millisecond},
+			want:     []int{5, 6},
+			wantErr:  "1 of 3 items failed",
+		},
+	}
+
+	defer func(c *hn.Client) { client = c }(client)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newTestItemServer(test.delays, test.failures)
+			defer server.Close()
+			client = hn.NewClient(hn.WithBaseURL(server.URL), hn.WithHTTPClient(server.Client()))
+
+			var out bytes.Buffer
+			err := fetchItems(strings.NewReader(test.input), &out, test.parallel, false, "html")
+			if test.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if test.wantErr != "" && (err == nil || err.Error() != test.wantErr) {
+				t.Fatalf("got error %v, want %q", err, test.wantErr)
+			}
+
+			got := make([]int, 0)
+			decoder := json.NewDecoder(&out)
+			for decoder.More() {
+				var item hn.Item
+				if err := decoder.Decode(&item); err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, item.ID)
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got items %v, want %v", got, test.want)
+			}
+		})
+	}
+}
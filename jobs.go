@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"hn/hn"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+func runJobs(args []string) error {
+	var postsToFetch int
+	var front bool
+	var location string
+	var companyMatch string
+	var roleMatch string
+	var patterns patternFlag
+	var format string
+
+	defaultFormat := "json"
+	if isTerminal(os.Stdout) {
+		defaultFormat = "human"
+	}
+
+	flags := flag.NewFlagSet("jobs", flag.ContinueOnError)
+	flags.IntVar(&postsToFetch, "posts", 30, "How many posts of the jobs section to fetch. A positive integer <= 100.")
+	flags.BoolVar(&front, "front", true, "Also list the jobs advertised on the front page")
+	flags.StringVar(&location, "location", "", "Only list jobs whose location has this in it, regardless of case, remote also lists jobs that say they are remote")
+	flags.StringVar(&companyMatch, "company-match", "", "Only list jobs whose company and batch, as in \"Acme (YC S24)\", match this regular expression")
+	flags.StringVar(&roleMatch, "role-match", "", "Only list jobs whose role matches this regular expression")
+	flags.Var(&patterns, "pattern", "A regular expression for job titles with the named groups company, batch, role or location, may be repeated, tried before the built in ones")
+	flags.StringVar(&format, "format", defaultFormat, "Output format, json or human (default human in a terminal, otherwise json)")
+
+	clientOptions := addClientFlags(flags)
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if err := clientOptions.apply(); err != nil {
+		return err
+	}
+
+	if postsToFetch < 1 || postsToFetch > 100 {
+		return errors.New("Posts must be between 1 and 100, inclusive.")
+	}
+
+	if format != "json" && format != "human" {
+		return fmt.Errorf("unknown format %q, must be json or human", format)
+	}
+
+	var company, role *regexp.Regexp
+	if companyMatch != "" {
+		if company, err = regexp.Compile(companyMatch); err != nil {
+			return fmt.Errorf("company-match: %v", err)
+		}
+	}
+	if roleMatch != "" {
+		if role, err = regexp.Compile(roleMatch); err != nil {
+			return fmt.Errorf("role-match: %v", err)
+		}
+	}
+
+	var posts hn.Posts
+	if front {
+		posts, err = client.FetchSections([]string{hn.SectionJobs, hn.SectionTop}, postsToFetch)
+	} else {
+		posts, err = client.FetchPosts(hn.SectionJobs, postsToFetch)
+	}
+	if err != nil {
+		return err
+	}
+
+	// The patterns of the command line come first, the built in ones catch the rest
+	if len(patterns) > 0 {
+		patterns = append(patterns, hn.JobPatterns...)
+	}
+
+	jobs := make([]hn.Job, 0)
+	for _, job := range hn.ParseJobs(posts, patterns) {
+		if location != "" && !matchesLocation(job, location) {
+			continue
+		}
+		if company != nil && !company.MatchString(jobCompany(job)) {
+			continue
+		}
+		if role != nil && !role.MatchString(job.Role) {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+
+	if format == "human" {
+		return writeJobs(os.Stdout, jobs)
+	}
+	return writeIndentedJSON(jobs)
+}
+
+// matchesLocation is whether the location of a job has location in it, or the job is remote when location is
+func matchesLocation(job hn.Job, location string) bool {
+	if strings.EqualFold(location, "remote") && job.Remote {
+		return true
+	}
+	return strings.Contains(strings.ToLower(job.Location), strings.ToLower(location))
+}
+
+// jobCompany is the company of a job with its batch, the way titles name them
+func jobCompany(job hn.Job) string {
+	if job.Batch == "" {
+		return job.Company
+	}
+	return fmt.Sprintf("%s (YC %s)", job.Company, job.Batch)
+}
+
+// writeJobs writes who is hiring for what and where, and the link to the job
+func writeJobs(w io.Writer, jobs []hn.Job) error {
+	for _, job := range jobs {
+		company, role, location := jobCompany(job), job.Role, job.Location
+		if company == "" {
+			// A title none of the patterns matched is all there is to show
+			company = job.Title
+		}
+		if role == "" {
+			role = "-"
+		}
+		if location == "" && job.Remote {
+			location = "Remote"
+		}
+
+		line := strings.TrimRight(fmt.Sprintf("%-28s %-32s %s", company, role, location), " ")
+		if _, err := fmt.Fprintf(w, "%s\n  %s\n", line, job.URL); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// patternFlag is repeated rather than comma separated, as regular expressions have commas in them
+type patternFlag []*regexp.Regexp
+
+func (f *patternFlag) String() string {
+	patterns := make([]string, len(*f))
+	for i, pattern := range *f {
+		patterns[i] = pattern.String()
+	}
+	return strings.Join(patterns, " ")
+}
+
+func (f *patternFlag) Set(value string) error {
+	pattern, err := hn.CompileJobPattern(value)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, pattern)
+	return nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// bellNotifier rings the terminal bell, for when nothing richer is available
+type bellNotifier struct{}
+
+func newBellNotifier(u *url.URL) (notifier, error) {
+	return bellNotifier{}, nil
+}
+
+func (bellNotifier) Notify(event Event) error {
+	// Prefer the controlling terminal so the bell does not end up in redirected output
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		_, err = os.Stderr.WriteString("\a")
+		return err
+	}
+	defer tty.Close()
+
+	_, err = tty.WriteString("\a")
+	return err
+}
+
+// pipeNotifier writes a line per event to a named pipe or unix socket that
+// something else, such as a status bar, is reading from
+type pipeNotifier struct {
+	path string
+	text bool
+}
+
+// newPipeNotifier configures a notifier from pipe:/path, writing JSON lines
+// unless ?format=text is given
+func newPipeNotifier(u *url.URL) (notifier, error) {
+	path := u.Path
+	if path == "" {
+		path = u.Opaque
+	}
+
+	if path == "" {
+		return nil, errors.New("pipe sink requires a path, e.g. pipe:/tmp/hn.fifo")
+	}
+
+	n := &pipeNotifier{path: path}
+	switch format := u.Query().Get("format"); format {
+	case "", "json":
+	case "text":
+		n.text = true
+	default:
+		return nil, fmt.Errorf("unknown pipe format %q, must be json or text", format)
+	}
+
+	return n, nil
+}
+
+func (n *pipeNotifier) Notify(event Event) error {
+	info, err := os.Stat(n.path)
+	if err != nil {
+		return err
+	}
+
+	var line []byte
+	if n.text {
+		line = []byte(strings.Replace(eventMessage(event), "\n", " ", -1) + "\n")
+	} else {
+		line, err = json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		line = append(line, '\n')
+	}
+
+	if info.Mode()&os.ModeSocket != 0 {
+		conn, err := net.Dial("unix", n.path)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		_, err = conn.Write(line)
+		return err
+	}
+
+	if info.Mode()&os.ModeNamedPipe == 0 {
+		return fmt.Errorf("%s is not a named pipe or unix socket", n.path)
+	}
+
+	// Without a reader the open fails rather than blocking the watch
+	pipe, err := os.OpenFile(n.path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return err
+	}
+	defer pipe.Close()
+
+	_, err = pipe.Write(line)
+	return err
+}
+
+// socketNotifier listens on a unix socket and streams events as JSON lines to
+// every connected client, such as a status bar or window manager widget
+type socketNotifier struct {
+	mutex   sync.Mutex
+	clients map[net.Conn]bool
+}
+
+// newSocketNotifier listens on unix:/path, replacing a stale socket from a previous run
+func newSocketNotifier(u *url.URL) (notifier, error) {
+	path := u.Path
+	if path == "" {
+		path = u.Opaque
+	}
+
+	if path == "" {
+		return nil, errors.New("unix sink requires a path, e.g. unix:/run/hn.sock")
+	}
+
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &socketNotifier{clients: make(map[net.Conn]bool)}
+	go n.accept(listener)
+
+	return n, nil
+}
+
+func (n *socketNotifier) accept(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			slog.Error("accepting connections failed", "error", err)
+			return
+		}
+
+		n.mutex.Lock()
+		n.clients[conn] = true
+		n.mutex.Unlock()
+	}
+}
+
+func (n *socketNotifier) Notify(event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	// A client that can not keep up, or has gone away, is dropped
+	for conn := range n.clients {
+		err := conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+		if err == nil {
+			_, err = conn.Write(line)
+		}
+
+		if err != nil {
+			conn.Close()
+			delete(n.clients, conn)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// logLevels are the levels of -log-level, debug also logs every page fetched and how each post was parsed
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// newLogger logs at a level and above to w, as text for people or as JSON, an object a line, for log collectors
+func newLogger(w io.Writer, level string, format string) (*slog.Logger, error) {
+	l, ok := logLevels[level]
+	if !ok {
+		return nil, fmt.Errorf("unknown log level %q, must be debug, info, warn or error", level)
+	}
+
+	options := &slog.HandlerOptions{Level: l}
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	}
+
+	return nil, fmt.Errorf("unknown log format %q, must be text or json", format)
+}
@@ -1,443 +1,705 @@
 package main
 
 import (
-	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
-	"golang.org/x/net/html"
-	"log"
-	"math"
-	"net/http"
+	"hn/hn"
+	"log/slog"
 	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
-	"strconv"
 	"strings"
+	"time"
 )
 
-// We must export it to allow JSON to marshal it
-type Post struct {
-	Title    string
-	URL      string
-	Author   string
-	Points   int
-	Comments int
-	Rank     int
+// client fetches every page, from news.ycombinator.com unless -base-url or -profile say otherwise
+var client = hn.NewClient()
+
+// clientFlags configure the client, every command that fetches pages has them
+type clientFlags struct {
+	rate       rateFlag
+	limits     hn.Limits
+	delay      delayFlag
+	politeness hn.Politeness
+	verbose    bool
+	logLevel   string
+	logFormat  string
+	dumpDir    string
+	streaming  bool
+	baseURL    string
+	profile    string
+	cacheTTL   time.Duration
+	cacheDir   string
+	noCache    bool
+	refresh    bool
+
+	// inMemory keeps recently used pages in memory in front of those on disk, for commands that run for long
+	inMemory bool
 }
 
-type Posts []Post
+// rateFlag is a rate such as 1rps, checked as it is set
+type rateFlag struct {
+	value string
+	rate  float64
+}
 
-type comparator func(node *html.Node) bool
+func (f *rateFlag) String() string {
+	return f.value
+}
 
-func findNode(n *html.Node, compare comparator) []*html.Node {
-	matches := make([]*html.Node, 0)
-	if n == nil {
-		return matches
+func (f *rateFlag) Set(value string) error {
+	rate, err := hn.ParseRate(value)
+	if err != nil {
+		return err
 	}
 
-	if compare(n) {
-		matches = append(matches, n)
-	}
+	f.value, f.rate = value, rate
+	return nil
+}
 
-	matches = append(matches, findNode(n.FirstChild, compare)...)
-	matches = append(matches, findNode(n.NextSibling, compare)...)
+// delayFlag is a delay between requests, or robots to take the Crawl-delay of robots.txt
+type delayFlag struct {
+	value  string
+	delay  time.Duration
+	robots bool
+}
 
-	return matches
+func (f *delayFlag) String() string {
+	return f.value
 }
 
-func prevSiblingUntil(node *html.Node, compare comparator) *html.Node {
-	for node.PrevSibling != nil {
-		node = node.PrevSibling
-		if node != nil && compare(node) {
-			return node
-		}
+func (f *delayFlag) Set(value string) error {
+	if value == "robots" {
+		f.value, f.delay, f.robots = value, 0, true
+		return nil
+	}
+
+	delay, err := time.ParseDuration(value)
+	if err != nil || delay < 0 {
+		return fmt.Errorf("invalid delay %q, e.g. 2s or robots", value)
 	}
+
+	f.value, f.delay, f.robots = value, delay, false
 	return nil
 }
 
-func hasAttribute(key string, value string, attrs []html.Attribute) bool {
-	attr := getAttribute(key, attrs)
+func addClientFlags(flags *flag.FlagSet) *clientFlags {
+	f := &clientFlags{}
+	f.rate.Set("1rps")
+	flags.Var(&f.rate, "rate", "Limit requests to HN across all fetches, e.g. 1rps, 30rpm or unlimited")
+	flags.Int64Var(&f.limits.ResponseBytes, "max-response-bytes", hn.DefaultLimits.ResponseBytes, "Fail on responses larger than this many bytes, 0 is unlimited")
+	flags.IntVar(&f.limits.Nodes, "max-nodes", hn.DefaultLimits.Nodes, "Fail on pages with more HTML nodes than this, 0 is unlimited")
+	flags.IntVar(&f.limits.CommentDepth, "max-comment-depth", hn.DefaultLimits.CommentDepth, "Fail on comments nested deeper than this, 0 is unlimited")
+	flags.IntVar(&f.limits.TextLength, "max-text-length", hn.DefaultLimits.TextLength, "Cut titles and authors longer than this many characters, 0 is unlimited")
+	f.delay.Set("0s")
+	flags.Var(&f.delay, "delay", "Wait at least this long between requests, whatever the rate, or robots for the Crawl-delay of robots.txt")
+	flags.IntVar(&f.politeness.Pages, "page-budget", 0, "Fail rather than fetch more than this many pages in a run, 0 is unlimited")
+	flags.IntVar(&f.politeness.Requests, "request-budget", 0, "Fail rather than send more than this many requests in a run, 0 is unlimited")
+	flags.BoolVar(&f.verbose, "v", false, "Log every page fetched, how long it took, and which fields of each post fell back to defaults, the same as -log-level=debug")
+	flags.StringVar(&f.logLevel, "log-level", "info", "Log at this level and above, debug, info, warn or error")
+	flags.StringVar(&f.logFormat, "log-format", "text", "Log as text, or json for log collectors")
+	flags.StringVar(&f.dumpDir, "debug-dump-html", "", "Save pages that fail to parse in this directory, to report markup changes with")
+	flags.BoolVar(&f.streaming, "stream-parse", false, "Parse pages a row at a time rather than as a whole, using less memory on items with thousands of comments")
+	flags.StringVar(&f.baseURL, "base-url", "", "Fetch from a site running the code of HN rather than news.ycombinator.com, e.g. https://news.example.org/ (default that of the profile)")
+	flags.StringVar(&f.profile, "profile", hn.DefaultProfile.Name, "The markup of the site, the name of a profile or a JSON file of the classes that differ from HN")
+	flags.DurationVar(&f.cacheTTL, "cache-ttl", 0, "Cache pages for this long and read them from the cache meanwhile, e.g. 5m, 0 does not cache")
+	flags.StringVar(&f.cacheDir, "cache-dir", "", "Where to cache pages (default hn in the cache directory of the user, e.g. ~/.cache/hn)")
+	flags.BoolVar(&f.noCache, "no-cache", false, "Neither read nor cache pages, whatever -cache-ttl is")
+	flags.BoolVar(&f.refresh, "refresh", false, "Fetch pages anew though they are cached, and cache them again")
+	return f
+}
 
-	if attr == nil {
-		return false
+// apply configures the client once the flags are parsed
+func (f *clientFlags) apply() error {
+	// Set up first, so the errors of the other flags are logged as asked too
+	if f.verbose {
+		f.logLevel = "debug"
 	}
+	logger, err := newLogger(os.Stderr, f.logLevel, f.logFormat)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
 
-	if attr.Val != value {
-		return false
+	if f.limits.ResponseBytes < 0 || f.limits.Nodes < 0 || f.limits.CommentDepth < 0 || f.limits.TextLength < 0 {
+		return errors.New("limits must not be negative")
 	}
 
-	return true
-}
+	if f.politeness.Pages < 0 || f.politeness.Requests < 0 {
+		return errors.New("budgets must not be negative")
+	}
 
-/**
- * Get an attribute
- *
- * If we are doing this a lot, it probably makes sense to create a hash table
- * to look up the attributes, returning look up to on Θ(1) vs Θ(n) with an array
- */
-func getAttribute(key string, attrs []html.Attribute) *html.Attribute {
-	for _, attr := range attrs {
-		if attr.Key == key {
-			return &attr
-		}
+	if f.cacheTTL < 0 {
+		return errors.New("cache-ttl must not be negative")
 	}
 
-	return nil
-}
+	f.politeness.Delay = f.delay.delay
 
-func findByClass(class string) comparator {
-	return func(n *html.Node) bool {
-		if n.Type != html.ElementNode {
-			return false
-		}
+	// The client logs its pages and posts at debug level
+	options := []hn.Option{hn.WithLimits(f.limits), hn.WithPoliteness(f.politeness), hn.WithLogger(logger)}
 
-		return hasAttribute("class", class, n.Attr)
+	// The profile comes first, so -base-url replaces the site of the profile
+	profile, err := loadProfile(f.profile)
+	if err != nil {
+		return err
 	}
-}
+	options = append(options, hn.WithProfile(profile))
 
-func min(x int, y int) int {
-	return int(math.Min(float64(x), float64(y)))
-}
-
-func getURL(node *html.Node) (string, error) {
-	nodes := findNode(node.FirstChild, findByClass("storylink"))
-	if len(nodes) != 1 {
-		return "", errors.New("uri nodes length is not exactly one")
+	if f.baseURL != "" {
+		u, err := url.Parse(f.baseURL)
+		if err != nil || !u.IsAbs() || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("base-url %q must be an http or https url", f.baseURL)
+		}
+		options = append(options, hn.WithBaseURL(f.baseURL))
 	}
-
-	node = nodes[0]
-	if node.Type != html.ElementNode {
-		return "", errors.New("uri node type is not an element node")
+	if f.rate.rate > 0 {
+		options = append(options, hn.WithRateLimiter(hn.NewRateLimiter(f.rate.rate, 1)))
 	}
 
-	if node.Data != "a" {
-		return "", errors.New("uri node is not an anchor")
+	if f.streaming {
+		options = append(options, hn.WithStreaming())
 	}
 
-	href := getAttribute("href", node.Attr)
-	if href == nil {
-		return "", errors.New("uri node does not have a href attribute")
+	if f.dumpDir != "" {
+		if err := os.MkdirAll(f.dumpDir, 0700); err != nil {
+			return err
+		}
+		options = append(options, hn.WithParseErrorHandler(dumpPage(f.dumpDir)))
 	}
 
-	u, err := url.Parse(href.Val)
-	if err != nil {
-		return "", err
+	if f.cacheTTL > 0 && !f.noCache {
+		cache, err := f.cache()
+		if err != nil {
+			return err
+		}
+		options = append(options, hn.WithCache(cache))
 	}
-	return u.String(), nil
-}
 
-func getTitle(node *html.Node) (string, error) {
-	nodes := findNode(node.FirstChild, findByClass("storylink"))
-	if len(nodes) != 1 {
-		return "", errors.New("author nodes length is not exactly one")
+	if f.refresh {
+		options = append(options, hn.WithRefresh())
 	}
 
-	firstChild := nodes[0].FirstChild
-	if firstChild == nil {
-		return "", errors.New("author node does not have any children")
-	}
+	client = hn.NewClient(options...)
 
-	if firstChild.Type != html.TextNode {
-		return "", errors.New("author node child is not a text node")
+	// Reading robots.txt is a request like any other, within the budget
+	if f.delay.robots {
+		delay, err := client.CrawlDelay("hn")
+		if err != nil {
+			return err
+		}
+		client.Politeness.Delay = delay
 	}
 
-	return firstChild.Data[0:min(len(firstChild.Data), 256)], nil
+	return nil
 }
 
-func getAuthor(node *html.Node) (string, error) {
-	nodes := findNode(node, findByClass("hnuser"))
-	if len(nodes) != 1 {
-		return "", errors.New("author nodes length is not exactly one")
+// cache is where the client keeps pages, on disk so runs share them, with the
+// most recently used also in memory for commands that run for long
+func (f *clientFlags) cache() (hn.Cache, error) {
+	dir := f.cacheDir
+	if dir == "" {
+		userDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("finding the cache directory, set -cache-dir: %v", err)
+		}
+		dir = filepath.Join(userDir, "hn")
 	}
 
-	firstChild := nodes[0].FirstChild
-	if firstChild == nil {
-		return "", errors.New("author node does not have any children")
+	disk, err := newDiskCache(dir, f.cacheTTL)
+	if err != nil {
+		return nil, err
 	}
 
-	if firstChild.Type != html.TextNode {
-		return "", errors.New("author node child is not a text node")
+	if !f.inMemory {
+		return disk, nil
 	}
-
-	return firstChild.Data[0:min(len(firstChild.Data), 256)], nil
+	return hn.Tiers{hn.NewMemoryCache(memoryCacheSize, f.cacheTTL), disk}, nil
 }
 
-func getRank(node *html.Node) (int, error) {
-	nodes := findNode(node.FirstChild, findByClass("rank"))
-	if len(nodes) != 1 {
-		return -1, errors.New("rank nodes length is not exactly one")
-	}
+// memoryCacheSize is how many pages are kept in memory, a few hundred KiB each
+const memoryCacheSize = 256
 
-	firstChild := nodes[0].FirstChild
-	if firstChild == nil {
-		return -1, errors.New("rank node does not have any children")
-	}
+// dumpPage saves a page that failed to parse, named after its url and when it was fetched
+func dumpPage(dir string) func(u string, page []byte, err error) {
+	unsafe := regexp.MustCompile(`[^A-Za-z0-9._-]+`)
 
-	if firstChild.Type != html.TextNode {
-		return -1, errors.New("rank node child is not a text node")
+	return func(u string, page []byte, err error) {
+		name := time.Now().UTC().Format("20060102T150405.000Z") + "-" + unsafe.ReplaceAllString(u, "_")
+		if len(name) > 200 {
+			name = name[:200]
+		}
+		path := filepath.Join(dir, name+".html")
+
+		if werr := os.WriteFile(path, page, 0600); werr != nil {
+			slog.Error("saving page failed", "url", u, "error", werr)
+			return
+		}
+		slog.Warn("saved page that failed to parse", "url", u, "path", path, "error", err)
 	}
+}
 
-	rank, err := strconv.Atoi(strings.Replace(firstChild.Data, ".", "", 1))
-	if err != nil {
-		return -1, errors.New("rank failed to convert to integer")
+func listSection(newPosts bool) string {
+	if newPosts {
+		return hn.SectionNew
 	}
+	return hn.SectionTop
+}
 
-	return rank, nil
+// sections are the names of the sections on the command line
+var sections = map[string]string{
+	"top":  hn.SectionTop,
+	"new":  hn.SectionNew,
+	"best": hn.SectionBest,
+	"ask":  hn.SectionAsk,
+	"show": hn.SectionShow,
+	"jobs": hn.SectionJobs,
 }
 
-func getPoints(node *html.Node) (int, error) {
-	nodes := findNode(node, findByClass("score"))
-	if len(nodes) != 1 {
-		return -1, errors.New("point nodes length is not exactly one")
+// sectionName is the name of a section on the command line, the section itself for one without a name
+func sectionName(section string) string {
+	for name, s := range sections {
+		if s == section {
+			return name
+		}
 	}
+	return section
+}
 
-	firstChild := nodes[0].FirstChild
-	if firstChild == nil {
-		return -1, errors.New("point node does not have any children")
+func getSection(name string) (string, error) {
+	section, ok := sections[name]
+	if !ok {
+		return "", fmt.Errorf("unknown section %q, must be top, new, best, ask, show or jobs", name)
 	}
+	return section, nil
+}
 
-	if firstChild.Type != html.TextNode {
-		return -1, errors.New("point node child is not a text node")
+// A command runs a sub command with the remaining arguments
+type command func(args []string) error
+
+// commands is filled in by init, as checking the config runs every command
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"check-links": runCheckLinks,
+		"comments":    runComments,
+		"config":      runConfig,
+		"crawl":       runCrawl,
+		"daemon":      runDaemon,
+		"digest":      runDigest,
+		"dupes":       runDupes,
+		"init":        runInit,
+		"item":        runItem,
+		"jobs":        runJobs,
+		"login":       runLogin,
+		"logout":      runLogout,
+		"lsp-ish":     runRPC,
+		"open":        runOpen,
+		"read":        runRead,
+		"reply":       runReply,
+		"schema":      runSchema,
+		"serve":       runServe,
+		"submit":      runSubmit,
+		"user":        runUser,
+		"watch":       runWatch,
 	}
+}
 
-	var re = regexp.MustCompile(`\D*points?`)
-
-	points, err := strconv.Atoi(re.ReplaceAllString(firstChild.Data, ""))
-	if err != nil {
-		return -1, errors.New("point failed to convert to integer")
+func main() {
+	args := os.Args[1:]
+
+	// Without a known sub command we list posts, which keeps `hn -posts=1` working
+	run := runList
+	if len(args) > 0 {
+		if cmd, ok := commands[args[0]]; ok {
+			run = cmd
+			args = args[1:]
+		}
 	}
 
-	return points, nil
+	err := run(args)
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		slog.Error(err.Error())
+	}
+	os.Exit(exitCode(err))
 }
 
-
-func isAdvertisement(node *html.Node) (bool, error) {
-	textNode, err := getCommentNode(node)
+func runList(args []string) error {
+	var postsToFetch int
+	var newPosts bool
+	var target openTarget
+	var format string
+	var noColor bool
+	var names stringList
+	var envelope bool
+	var bySection bool
+	var fieldNames stringList
+	var newerThan, olderThan ageFlag
+	var maxPages int
+	var untilID int
+	var untilTime string
+	var plugins stringList
+	var enrichOG bool
+	var enrichTimeout time.Duration
+	var enrichParallel int
+	var archive bool
+	var archiveRate rateFlag
+	var archiveKeys string
+	var failOnPartial bool
+	var withTopComment bool
+	var topCommentParallel int
+
+	// Humans get columns in a terminal, anything else gets JSON
+	defaultFormat := "json"
+	if isTerminal(os.Stdout) {
+		defaultFormat = "human"
+	}
+
+	flags := flag.NewFlagSet(configFlagSet, flag.ContinueOnError)
+	flags.IntVar(&postsToFetch, "posts", 30, "How many posts to print. A positive integer <= 100.")
+	flags.BoolVar(&newPosts, "new", false, "Whether to fetch posts from newest as opposed to front page (default false)")
+	flags.Var(&names, "section", "Sections to list, top, new, best, ask, show or jobs. Several, e.g. top,new,best, are merged without duplicates (default top)")
+	flags.Var(&target, "open", "Open each post in the browser, either the story or its comments (-open=comments)")
+	flags.StringVar(&format, "format", defaultFormat, "Output format, json, human, statusbar, xbar, opml, netscape-bookmarks or parquet (default human in a terminal, otherwise json)")
+	flags.BoolVar(&noColor, "no-color", false, "Disable colors in human output")
+	flags.Var(&newerThan, "newer-than", "Only list posts submitted within this age, e.g. 6h or 2d. On newest, pages are followed until older posts")
+	flags.Var(&olderThan, "older-than", "Only list posts submitted longer ago than this age, e.g. 2d or 1w")
+	flags.IntVar(&untilID, "until-id", 0, "On newest, follow pages until this post id or older, e.g. the newest id of the last run")
+	flags.StringVar(&untilTime, "until-time", "", "On newest, follow pages until posts submitted at this time or before, RFC 3339 or unix seconds")
+	flags.IntVar(&maxPages, "max-pages", 10, "The most pages of newest to follow for -newer-than, -until-id and -until-time")
+	filters := addFilterFlags(flags)
+	flags.Var(&plugins, "plugin", "Process posts with a Go plugin exporting Process(hn.Post) (hn.Post, bool), may be repeated")
+	flags.Var(&fieldNames, "fields", "Only write these fields of each post, e.g. title,url,points, for the json and human formats")
+	flags.BoolVar(&bySection, "by-section", false, "With several sections, write a JSON object of the posts of each section, keyed by section as named in -section, rather than merging them")
+	flags.BoolVar(&envelope, "envelope", false, "Wrap JSON output with the schema version, fetch time, sections, page count and parse warnings")
+	flags.BoolVar(&failOnPartial, "fail-on-partial", false, "Exit with 4 after writing posts that are partial, such as fewer than asked for or without a preview")
+	flags.BoolVar(&enrichOG, "enrich-og", false, "Fetch the page of each story and add its OpenGraph title, description, image and favicon as Preview")
+	flags.DurationVar(&enrichTimeout, "enrich-timeout", 5*time.Second, "How long -enrich-og waits for each page")
+	flags.IntVar(&enrichParallel, "enrich-parallel", 8, "How many pages -enrich-og fetches at once")
+	flags.BoolVar(&withTopComment, "with-top-comment", false, "Fetch the item page of each story and add its highest ranked comment, the author and the start of its text, as TopComment")
+	flags.IntVar(&topCommentParallel, "top-comment-parallel", 4, "How many item pages -with-top-comment fetches at once")
+	flags.BoolVar(&archive, "archive", false, "Save the page of each story to the Wayback Machine, recording the snapshot or error as Archive")
+	archiveRate.Set("12rpm")
+	flags.Var(&archiveRate, "archive-rate", "Limit saves to the Wayback Machine, e.g. 12rpm")
+	flags.StringVar(&archiveKeys, "archive-keys", "", "The archive.org S3 keys to save with, access:secret or a secret such as env:NAME (default $HN_ARCHIVE_KEYS)")
+
+	clientOptions := addClientFlags(flags)
+
+	err := parseFlags(flags, args)
 	if err != nil {
-		return false, err
+		return err
 	}
 
-	if textNode.Data == "hide" {
-		return true, nil
+	if err := clientOptions.apply(); err != nil {
+		return err
 	}
 
-	return false, nil
-}
-
-func getCommentNode(node *html.Node) (*html.Node, error) {
-	subTextNode := findNode(node, findByClass("subtext"))
-	if len(subTextNode) != 1 {
-		return nil, errors.New("comment parent nodes length is not exactly one")
+	// Listing takes no arguments, anything left is a mistake such as a misspelt command
+	if flags.NArg() > 0 {
+		return fmt.Errorf("unknown command %q", flags.Arg(0))
 	}
 
-	commentNode := prevSiblingUntil(subTextNode[0].LastChild, func(node *html.Node) bool {
-		return node.Type == html.ElementNode
-	})
-	if commentNode == nil {
-		return nil, errors.New("comment node is nil")
+	if postsToFetch < 1 || postsToFetch > 100 {
+		return errors.New("Posts must be between 1 and 100, inclusive.")
 	}
 
-	textNode := commentNode.FirstChild
-	if textNode == nil {
-		return nil, errors.New("comment node does not have any children")
+	if maxPages < 1 {
+		return errors.New("max-pages must be at least 1")
 	}
 
-	if textNode.Type != html.TextNode {
-		return nil, errors.New("comment node child is not a text node")
+	if enrichParallel < 1 {
+		return errors.New("enrich-parallel must be at least 1")
 	}
 
-	return textNode, nil
-}
-
-func getComments(node *html.Node) (int, error) {
-	textNode, err := getCommentNode(node)
-	if err != nil {
-		return -1, err
+	if topCommentParallel < 1 {
+		return errors.New("top-comment-parallel must be at least 1")
 	}
 
-	if textNode.Data == "discuss" {
-		return 0, nil
+	// Plugins process posts first, so filters see what they made of them
+	for _, path := range plugins {
+		process, err := loadPlugin(path)
+		if err != nil {
+			return err
+		}
+		client.Pipeline = append(client.Pipeline, process)
 	}
 
-	var re = regexp.MustCompile(`\D*comments?`)
+	if err := applyFilters(*filters); err != nil {
+		return err
+	}
 
-	comments, err := strconv.Atoi(re.ReplaceAllString(textNode.Data, ""))
-	if err != nil {
-		return -1, errors.New("comments failed to convert to integer")
+	// Keys are checked before anything is fetched
+	var archiver *hn.Archiver
+	if archive {
+		if archiver, err = newArchiver(archiveKeys, archiveRate); err != nil {
+			return err
+		}
 	}
 
-	return comments, nil
-}
+	var untilCutoff time.Time
+	if untilTime != "" {
+		untilCutoff, err = parseTime(untilTime)
+		if err != nil {
+			return err
+		}
+	}
 
-func fetch(url string, page int, results chan result, errors chan error) {
-	// TODO: Consider sending Accept, Language and User-Agent headers
-	// TODO: Ideally we should a url builder here to ensure valid urls are generated
-	resp, err := http.Get(url + "?p=" + strconv.Itoa(page))
+	// Colors are only for terminals, NO_COLOR is the common convention to opt out
+	color := !noColor && os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout)
+	write, err := getFormatter(format, color)
 	if err != nil {
-		errors <- err
-		return
+		return err
 	}
 
-	node, err := html.Parse(resp.Body)
+	fields, err := parseFields(fieldNames)
 	if err != nil {
-		errors <- err
-		return
+		return err
+	}
+	if len(fields) > 0 {
+		write, err = fieldsFormatter(format, fields)
+		if err != nil {
+			return err
+		}
 	}
 
-	posts, err := getPosts(node)
-	if err != nil {
-		errors <- err
-		return
+	if envelope && format != "json" {
+		return errors.New("-envelope is only for -format=json")
 	}
 
-	results <- result{
-		page: page,
-		posts: posts,
+	if bySection && format != "json" {
+		return errors.New("-by-section is only for -format=json")
 	}
-}
 
-type result struct {
-	page int
-	posts Posts
-}
+	if newPosts && len(names) > 0 {
+		return errors.New("use either -new or -section=new")
+	}
 
-func main() {
-	var postsToFetch int
-	var newPosts bool
+	listSections := []string{listSection(newPosts)}
+	if len(names) > 0 {
+		listSections = make([]string, 0, len(names))
+		for _, name := range names {
+			section, err := getSection(name)
+			if err != nil {
+				return err
+			}
+			listSections = append(listSections, section)
+		}
+	}
 
-	flags := flag.NewFlagSet("main", flag.ExitOnError)
-	flags.IntVar(&postsToFetch, "posts", 30, "How many posts to print. A positive integer <= 100.")
-	flags.BoolVar(&newPosts, "new", false, "Whether to fetch posts from newest as opposed to front page (default false)")
+	// Each post of several sections lists the sections it was in, and its rank in them
+	fetchedAt := time.Now().UTC()
+	pages := (postsToFetch + hn.PostsPerPage - 1) / hn.PostsPerPage * len(listSections)
 
-	err := flags.Parse(os.Args[1:])
-	if err != nil {
-		log.Fatal(err)
+	var newerCutoff, olderCutoff time.Time
+	if newerThan.age > 0 {
+		newerCutoff = fetchedAt.Add(-newerThan.age)
 	}
-
-	if postsToFetch < 0 && postsToFetch > 100 {
-		log.Fatalf("%s", "Posts must be between 1 and 100, inclusive.")
+	if olderThan.age > 0 {
+		olderCutoff = fetchedAt.Add(-olderThan.age)
 	}
 
-	resultChan := make(chan result)
-	errorChan := make(chan error)
-
-	postsPerPage := 30
-	pagesToFetch := math.Ceil(float64(postsToFetch) / float64(postsPerPage))
+	newest := len(listSections) == 1 && listSections[0] == hn.SectionNew
+	until := untilID > 0 || !untilCutoff.IsZero()
+	if until && !newest {
+		return errors.New("-until-id and -until-time are only for newest, use -new")
+	}
 
-	u := "https://news.ycombinator.com/"
-	if newPosts {
-		u += "newest"
+	// Newest is in order of submission, so rather than -posts it is listed until the cutoff
+	var posts hn.Posts
+	var reached bool
+	if newest && (newerThan.age > 0 || until) {
+		posts, pages, err = client.FetchUntil(hn.SectionNew, maxPages, func(post hn.Post) bool {
+			stop := (!newerCutoff.IsZero() && !post.Time.IsZero() && !post.Time.After(newerCutoff)) ||
+				(untilID > 0 && post.ID != 0 && post.ID <= untilID) ||
+				(!untilCutoff.IsZero() && !post.Time.IsZero() && !post.Time.After(untilCutoff))
+			reached = reached || stop
+			return stop
+		})
+	} else if len(listSections) > 1 {
+		posts, err = client.FetchSections(listSections, postsToFetch)
 	} else {
-		u += "news"
+		posts, err = client.FetchPosts(listSections[0], postsToFetch)
+	}
+	if err != nil {
+		return err
 	}
 
-	for page := 1.0; page <= pagesToFetch; page += 1.0 {
-		go fetch(u, int(page), resultChan, errorChan)
+	// Running out of pages before the last run would leave a gap in an incremental pipeline
+	var gap string
+	if until && !reached {
+		gap = fmt.Sprintf("stopped after %d pages without reaching the -until-id or -until-time, raise -max-pages to not miss posts", pages)
+		slog.Warn(gap)
 	}
 
-	pagesFetched := 0.0
-	posts := make([]Post, postsToFetch)
-Loop:
-	for {
-		select {
-		case result, ok := <-resultChan:
-			if !ok {
-				continue
-			}
-			pagesFetched += 1
-
-			offset := (result.page - 1) * postsPerPage
-			for index, post := range result.posts {
-				if (offset + index) > len(posts) - 1 {
-					break
-				}
-				posts[offset + index] = post
-			}
+	// Filters leave out an unknown number of posts, so fewer than asked for is expected
+	wanted := postsToFetch
+	if until || len(client.Pipeline) > 0 {
+		wanted = 0
+	}
+	if newerThan.age > 0 || olderThan.age > 0 {
+		posts = filterAge(posts, newerCutoff, olderCutoff)
+		wanted = 0
+	}
 
-			if int(pagesFetched) == int(pagesToFetch) {
-				break Loop
-			}
-		case err, ok := <-errorChan:
-			if !ok {
-				continue
-			}
-			log.Fatal(err)
-		default:
-			if errorChan == nil && resultChan == nil {
-				break Loop
+	// Story pages are on other sites, a page that fails only leaves its post without a preview
+	var previewErrors []string
+	if enrichOG {
+		enricher := hn.NewEnricher()
+		enricher.HTTPClient.Timeout = enrichTimeout
+		enricher.Parallel = enrichParallel
+		enricher.Enrich(posts)
+
+		for _, post := range posts {
+			if post.Preview != nil && post.Preview.Error != "" {
+				previewErrors = append(previewErrors, fmt.Sprintf("no preview of %d: %s", post.ID, post.Preview.Error))
 			}
 		}
+		if len(previewErrors) > 0 {
+			slog.Warn("stories have no preview, see their Preview.Error", "count", len(previewErrors), "stories", len(posts))
+		}
 	}
 
-	response, err := json.MarshalIndent(posts[0:postsToFetch], "", "    ")
-	if err != nil {
-		log.Fatal(err)
+	// Item pages are on HN, so they are fetched by the client, within its rate and budget
+	var topCommentErrors []string
+	if withTopComment {
+		client.FetchTopComments(posts, topCommentParallel)
+
+		for _, post := range posts {
+			if post.TopComment != nil && post.TopComment.Error != "" {
+				topCommentErrors = append(topCommentErrors, fmt.Sprintf("no top comment of %d: %s", post.ID, post.TopComment.Error))
+			}
+		}
+		if len(topCommentErrors) > 0 {
+			slog.Warn("stories have no top comment, see their TopComment.Error", "count", len(topCommentErrors), "stories", len(posts))
+		}
 	}
 
-	fmt.Println(string(response))
-}
+	// Saves are slow and limited, a save that fails is recorded rather than failing the listing
+	var archiveErrors []string
+	if archiver != nil {
+		archiver.Archive(posts)
 
-func getPosts(node *html.Node) (Posts, error) {
-	// NOTE: we could make this allocation more efficient by passing in the length and allocating up front
-	posts := make(Posts, 0)
-	for _, postNode := range findNode(node, findByClass("athing")) {
-		title, err := getTitle(postNode)
-		if err != nil {
-			return nil, err
+		for _, post := range posts {
+			if post.Archive != nil && post.Archive.Error != "" {
+				archiveErrors = append(archiveErrors, fmt.Sprintf("not archived %d: %s", post.ID, post.Archive.Error))
+			}
+		}
+		if len(archiveErrors) > 0 {
+			slog.Warn("stories were not archived, see their Archive.Error", "count", len(archiveErrors), "stories", len(posts))
 		}
+	}
 
-		u, err := getURL(postNode)
-		if err != nil {
-			return nil, err
+	if target != "" {
+		for _, post := range posts {
+			if err := openPost(post, target); err != nil {
+				return err
+			}
 		}
+	}
 
-		nextRow := postNode.NextSibling.FirstChild
-		// If nextRow is nil, it's likely we're at the end of the results
-		if nextRow == nil {
-			continue
+	// The sections are fetched and processed merged, so a post in several is only processed once
+	var keyed interface{}
+	if bySection {
+		// Keyed by the names they were asked for by, top rather than news
+		lists := make(map[string]hn.Posts, len(listSections))
+		for section, list := range hn.SplitSections(listSections, posts) {
+			lists[sectionName(section)] = list
 		}
+		keyed = lists
+		if len(fields) > 0 {
+			projected := make(map[string][]projectedPost, len(lists))
+			for section, list := range lists {
+				projected[section] = projectPosts(list, fields)
+			}
+			keyed = projected
+		}
+	}
 
-		author := "N/A"
-		points := -1
-		comments := -1
+	// What is missing, when asked to, fails the run once the posts are written
+	partial := partialWarnings(listSections, wanted, posts)
+	if gap != "" {
+		partial = append(partial, gap)
+	}
+	partial = append(partial, previewErrors...)
+	partial = append(partial, topCommentErrors...)
+	partial = append(partial, archiveErrors...)
 
-		isAd, err := isAdvertisement(nextRow)
+	if envelope {
+		e := newEnvelope(listSections, pages, wanted, fetchedAt, posts, fields)
+		if gap != "" {
+			e.Warnings = append(e.Warnings, gap)
+		}
+		e.Warnings = append(e.Warnings, previewErrors...)
+		e.Warnings = append(e.Warnings, topCommentErrors...)
+		e.Warnings = append(e.Warnings, archiveErrors...)
+		if keyed != nil {
+			e.Posts = keyed
+		}
+		err = writeIndentedJSON(e)
+	} else if keyed != nil {
+		err = writeIndentedJSON(keyed)
+	} else {
+		err = write(os.Stdout, posts)
+	}
+	if err != nil {
+		return err
+	}
 
-		if err != nil {
-			return nil, err
-		} else if !isAd {
-			author, err = getAuthor(nextRow)
-			if err != nil {
-				return nil, err
-			}
+	if failOnPartial && len(partial) > 0 {
+		return &partialError{warnings: partial}
+	}
+	return nil
+}
 
-			points, err = getPoints(nextRow)
-			if err != nil {
-				return nil, err
-			}
+// loadProfile looks up a profile by name, or reads it from a JSON file named
+// after it when no profile has the name
+func loadProfile(name string) (hn.Profile, error) {
+	if profile, ok := hn.LookupProfile(name); ok {
+		return profile, nil
+	}
 
-			comments, err = getComments(nextRow)
-			if err != nil {
-				return nil, err
-			}
-		}
+	f, err := os.Open(name)
+	if err != nil {
+		return hn.Profile{}, fmt.Errorf("unknown profile %q, must be one of %s or a JSON file", name, strings.Join(hn.ProfileNames(), ", "))
+	}
+	defer f.Close()
 
+	profile, err := hn.ReadProfile(f)
+	if err != nil {
+		return hn.Profile{}, fmt.Errorf("%s: %v", name, err)
+	}
+	if profile.Name == "" {
+		profile.Name = strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	}
+	return profile, nil
+}
 
-		rank, err := getRank(postNode)
-		if err != nil {
+// newArchiver saves with the archive.org keys, when there are any, from the
+// flag or HN_ARCHIVE_KEYS
+func newArchiver(keys string, rate rateFlag) (*hn.Archiver, error) {
+	var err error
+	if keys == "" {
+		if keys, err = getenvSecret("HN_ARCHIVE_KEYS"); err != nil {
 			return nil, err
 		}
+	}
+	if keys, err = resolveSecret(keys); err != nil {
+		return nil, err
+	}
 
-		post := Post{
-			Title:    title,
-			URL:      u,
-			Author:   author,
-			Points:   points,
-			Comments: comments,
-			Rank:     rank,
+	archiver := hn.NewArchiver()
+	if keys != "" {
+		i := strings.Index(keys, ":")
+		if i < 0 {
+			return nil, errors.New("archive keys must be access:secret")
 		}
-		posts = append(posts, post)
+		archiver.AccessKey, archiver.Secret = keys[:i], keys[i+1:]
 	}
-	return posts, nil
+	if rate.rate > 0 {
+		archiver.Limiter = hn.NewRateLimiter(rate.rate, 1)
+	}
+
+	return archiver, nil
 }
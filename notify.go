@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// A notifier delivers watch events to a sink
+type notifier interface {
+	Notify(event Event) error
+}
+
+// Sinks are configured as URLs, the scheme picks the notifier
+var sinkNotifiers = map[string]func(u *url.URL) (notifier, error){
+	"bell":   newBellNotifier,
+	"pipe":   newPipeNotifier,
+	"stdout": newStdoutNotifier,
+	"unix":   newSocketNotifier,
+	"xmpp":   newXMPPNotifier,
+}
+
+// newNotifier configures a notifier from a sink, or a reference to a secret sink such as env:HN_SINK
+func newNotifier(sink string) (notifier, error) {
+	value, err := resolveSecret(sink)
+	if err != nil {
+		return nil, err
+	}
+
+	// The parse error would quote the url, with any password in it
+	u, err := url.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sink %s", redactURL(sink))
+	}
+
+	// Sinks without any options, such as stdout, are a bare scheme
+	scheme := u.Scheme
+	if scheme == "" {
+		scheme = u.Path
+	}
+
+	create, ok := sinkNotifiers[scheme]
+	if !ok {
+		return nil, fmt.Errorf("unknown sink %q", redactURL(sink))
+	}
+
+	return create(u)
+}
+
+type stdoutNotifier struct {
+	encoder *json.Encoder
+}
+
+func newStdoutNotifier(u *url.URL) (notifier, error) {
+	return &stdoutNotifier{encoder: json.NewEncoder(os.Stdout)}, nil
+}
+
+func (n *stdoutNotifier) Notify(event Event) error {
+	return n.encoder.Encode(event)
+}
+
+// eventMessage is a short human readable description of an event for chat sinks
+func eventMessage(event Event) string {
+	var b strings.Builder
+
+	post := event.Post
+	switch event.Type {
+	case eventEntered:
+		fmt.Fprintf(&b, "#%d %s (%d points)", post.Rank, post.Title, post.Points)
+	case eventDropped:
+		fmt.Fprintf(&b, "Dropped: %s", post.Title)
+	case eventPoints:
+		fmt.Fprintf(&b, "#%d %s (%d → %d points)", post.Rank, post.Title, event.Previous.Points, post.Points)
+	default:
+		fmt.Fprintf(&b, "%s: %s", event.Type, post.Title)
+	}
+
+	fmt.Fprintf(&b, "\n%s\n%s", post.URL, client.ItemURL(post.ID))
+	return b.String()
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"hn/hn"
+	"os/exec"
+	"runtime"
+	"strconv"
+)
+
+// openTarget is what to open in the browser for a post, the story or its comments
+type openTarget string
+
+const (
+	openStory    openTarget = "story"
+	openComments openTarget = "comments"
+)
+
+func (t *openTarget) String() string {
+	return string(*t)
+}
+
+func (t *openTarget) Set(value string) error {
+	switch value {
+	case "true", string(openStory):
+		*t = openStory
+	case string(openComments):
+		*t = openComments
+	case "false":
+		*t = ""
+	default:
+		return fmt.Errorf("unknown open target %q, must be story or comments", value)
+	}
+	return nil
+}
+
+// IsBoolFlag allows -open to be passed without a value to open the story
+func (t *openTarget) IsBoolFlag() bool {
+	return true
+}
+
+// maxRank is the largest rank that can be listed, anything larger is an item id
+const maxRank = 100
+
+func runOpen(args []string) error {
+	var comments bool
+	var newPosts bool
+
+	flags := flag.NewFlagSet("open", flag.ContinueOnError)
+	flags.BoolVar(&comments, "comments", false, "Open the comments page instead of the story")
+	flags.BoolVar(&newPosts, "new", false, "Look up the rank in newest as opposed to front page (default false)")
+
+	clientOptions := addClientFlags(flags)
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if err := clientOptions.apply(); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("usage: hn open [-comments] [-new] <rank|id>")
+	}
+
+	n, err := strconv.Atoi(flags.Arg(0))
+	if err != nil || n < 1 {
+		return fmt.Errorf("%q is not a valid rank or id", flags.Arg(0))
+	}
+
+	target := openStory
+	if comments {
+		target = openComments
+	}
+
+	if n > maxRank && target == openComments {
+		return openBrowser(client.ItemURL(n))
+	}
+
+	post, err := findPost(n, newPosts)
+	if err != nil {
+		return err
+	}
+
+	return openPost(post, target)
+}
+
+// findPost is the post at a rank of the front page or newest, or the post
+// with an id. Ranks and ids share the argument, ids are always far larger than the ranks we list.
+func findPost(n int, newPosts bool) (hn.Post, error) {
+	if n > maxRank {
+		item, err := client.FetchItem(n)
+		if err != nil {
+			return hn.Post{}, err
+		}
+		return item.Post, nil
+	}
+
+	posts, err := client.FetchPosts(listSection(newPosts), n)
+	if err != nil {
+		return hn.Post{}, err
+	}
+
+	// A section can list fewer posts than the rank asked for
+	if len(posts) < n {
+		return hn.Post{}, fmt.Errorf("there is no post at rank %d, only %d are listed", n, len(posts))
+	}
+
+	return posts[n-1], nil
+}
+
+func openPost(post hn.Post, target openTarget) error {
+	if target == openComments {
+		return openBrowser(client.ItemURL(post.ID))
+	}
+
+	u, err := client.StoryURL(post)
+	if err != nil {
+		return err
+	}
+
+	return openBrowser(u)
+}
+
+// openBrowser launches the system browser, without waiting for it to exit
+func openBrowser(u string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", u)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", u)
+	default:
+		cmd = exec.Command("xdg-open", u)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to open %s: %v", u, err)
+	}
+
+	return cmd.Process.Release()
+}
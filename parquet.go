@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hn/hn"
+	"io"
+)
+
+// Parquet is written by hand rather than with a library, as posts only need a
+// small part of it, a row group of required columns with a plain encoded,
+// uncompressed page each. See https://github.com/apache/parquet-format
+
+const parquetMagic = "PAR1"
+
+// Physical types, repetitions, converted types and encodings of the format
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	parquetRequired = 0
+
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+
+	parquetPlain = 0
+	parquetRLE   = 3
+)
+
+// A parquetColumn is a column of posts, with its values encoded as they are written
+type parquetColumn struct {
+	name      string
+	kind      int32
+	converted int32
+	value     func(post hn.Post, w *parquetPage)
+}
+
+// parquetColumns are the flat fields of a post, by their names in JSON
+var parquetColumns = []parquetColumn{
+	{"ID", parquetInt64, -1, func(post hn.Post, w *parquetPage) { w.int64(int64(post.ID)) }},
+	{"Title", parquetByteArray, parquetUTF8, func(post hn.Post, w *parquetPage) { w.bytes(post.Title) }},
+	{"URL", parquetByteArray, parquetUTF8, func(post hn.Post, w *parquetPage) { w.bytes(post.URL) }},
+	{"Author", parquetByteArray, parquetUTF8, func(post hn.Post, w *parquetPage) { w.bytes(post.Author) }},
+	{"Points", parquetInt64, -1, func(post hn.Post, w *parquetPage) { w.int64(int64(post.Points)) }},
+	{"Comments", parquetInt64, -1, func(post hn.Post, w *parquetPage) { w.int64(int64(post.Comments)) }},
+	{"Rank", parquetInt64, -1, func(post hn.Post, w *parquetPage) { w.int64(int64(post.Rank)) }},
+	{"Time", parquetInt64, parquetTimestampMillis, func(post hn.Post, w *parquetPage) { w.int64(post.Time.UnixMilli()) }},
+	{"Section", parquetByteArray, parquetUTF8, func(post hn.Post, w *parquetPage) { w.bytes(post.Section) }},
+	{"SecondChance", parquetBoolean, -1, func(post hn.Post, w *parquetPage) { w.bool(post.SecondChance) }},
+}
+
+// writeParquet writes posts as a Parquet file, for pandas, DuckDB, Spark and
+// the like. Nested fields, such as previews, are left out.
+func writeParquet(w io.Writer, posts hn.Posts) error {
+	file := &bytes.Buffer{}
+	file.WriteString(parquetMagic)
+
+	chunks := make([]*thrift, 0, len(parquetColumns))
+	var rowGroupSize int64
+	for _, column := range parquetColumns {
+		page := &parquetPage{}
+		for _, post := range posts {
+			column.value(post, page)
+		}
+		data := page.finish()
+
+		header := &thrift{}
+		header.i32(1, 0) // a data page
+		header.i32(2, int32(len(data)))
+		header.i32(3, int32(len(data)))
+		header.structBegin(5)
+		header.i32(1, int32(len(posts)))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.structEnd()
+		header.stop()
+
+		offset := int64(file.Len())
+		file.Write(header.Bytes())
+		file.Write(data)
+		size := int64(file.Len()) - offset
+		rowGroupSize += size
+
+		chunk := &thrift{}
+		chunk.i64(2, offset)
+		chunk.structBegin(3)
+		chunk.i32(1, column.kind)
+		chunk.listBegin(2, thriftI32, 2)
+		chunk.varint(parquetPlain)
+		chunk.varint(parquetRLE)
+		chunk.listBegin(3, thriftBinary, 1)
+		chunk.binary(column.name)
+		chunk.i32(4, 0) // uncompressed
+		chunk.i64(5, int64(len(posts)))
+		chunk.i64(6, size)
+		chunk.i64(7, size)
+		chunk.i64(9, offset)
+		chunk.structEnd()
+		chunk.stop()
+		chunks = append(chunks, chunk)
+	}
+
+	footer := &thrift{}
+	footer.i32(1, 1)
+	footer.listBegin(2, thriftStruct, len(parquetColumns)+1)
+	root := &thrift{}
+	root.string(4, "post")
+	root.i32(5, int32(len(parquetColumns)))
+	root.stop()
+	footer.Write(root.Bytes())
+	for _, column := range parquetColumns {
+		element := &thrift{}
+		element.i32(1, column.kind)
+		element.i32(3, parquetRequired)
+		element.string(4, column.name)
+		if column.converted >= 0 {
+			element.i32(6, column.converted)
+		}
+		element.stop()
+		footer.Write(element.Bytes())
+	}
+	footer.i64(3, int64(len(posts)))
+	footer.listBegin(4, thriftStruct, 1)
+	rowGroup := &thrift{}
+	rowGroup.listBegin(1, thriftStruct, len(chunks))
+	for _, chunk := range chunks {
+		rowGroup.Write(chunk.Bytes())
+	}
+	rowGroup.i64(2, rowGroupSize)
+	rowGroup.i64(3, int64(len(posts)))
+	rowGroup.stop()
+	footer.Write(rowGroup.Bytes())
+	footer.string(6, "hn")
+	footer.stop()
+
+	file.Write(footer.Bytes())
+	binary.Write(file, binary.LittleEndian, uint32(footer.Len()))
+	file.WriteString(parquetMagic)
+
+	_, err := w.Write(file.Bytes())
+	return err
+}
+
+// A parquetPage is the values of a column, plain encoded
+type parquetPage struct {
+	bytes.Buffer
+	bits  byte
+	nbits uint
+}
+
+func (p *parquetPage) int64(v int64) {
+	binary.Write(p, binary.LittleEndian, v)
+}
+
+func (p *parquetPage) bytes(s string) {
+	binary.Write(p, binary.LittleEndian, uint32(len(s)))
+	p.WriteString(s)
+}
+
+// bool packs booleans into bytes, the first in the lowest bit
+func (p *parquetPage) bool(v bool) {
+	if v {
+		p.bits |= 1 << p.nbits
+	}
+	p.nbits++
+	if p.nbits == 8 {
+		p.WriteByte(p.bits)
+		p.bits, p.nbits = 0, 0
+	}
+}
+
+func (p *parquetPage) finish() []byte {
+	if p.nbits > 0 {
+		p.WriteByte(p.bits)
+		p.bits, p.nbits = 0, 0
+	}
+	return p.Bytes()
+}
+
+// Types of the Thrift compact protocol, which Parquet writes its metadata with
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// A thrift writes a struct with the compact protocol of Thrift, field ids
+// must be in order. Nested structs are either written in place with
+// structBegin and structEnd, or as thrifts of their own and copied in.
+type thrift struct {
+	bytes.Buffer
+	last    int16
+	parents []int16
+}
+
+func (t *thrift) field(id int16, kind byte) {
+	if delta := id - t.last; delta > 0 && delta <= 15 {
+		t.WriteByte(byte(delta)<<4 | kind)
+	} else {
+		t.WriteByte(kind)
+		t.varint(int64(id))
+	}
+	t.last = id
+}
+
+// varint writes an integer zigzag encoded, as the compact protocol does every i16, i32 and i64
+func (t *thrift) varint(v int64) {
+	t.uvarint(uint64(v<<1) ^ uint64(v>>63))
+}
+
+func (t *thrift) uvarint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	t.Write(buf[:binary.PutUvarint(buf[:], v)])
+}
+
+func (t *thrift) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(int64(v))
+}
+
+func (t *thrift) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(v)
+}
+
+func (t *thrift) string(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.binary(s)
+}
+
+func (t *thrift) binary(s string) {
+	t.uvarint(uint64(len(s)))
+	t.WriteString(s)
+}
+
+// listBegin starts a list of size elements, which are then written without field headers
+func (t *thrift) listBegin(id int16, kind byte, size int) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.WriteByte(byte(size)<<4 | kind)
+	} else {
+		t.WriteByte(0xf0 | kind)
+		t.uvarint(uint64(size))
+	}
+}
+
+func (t *thrift) structBegin(id int16) {
+	t.field(id, thriftStruct)
+	t.parents = append(t.parents, t.last)
+	t.last = 0
+}
+
+func (t *thrift) structEnd() {
+	t.stop()
+	t.last = t.parents[len(t.parents)-1]
+	t.parents = t.parents[:len(t.parents)-1]
+}
+
+// stop ends a struct
+func (t *thrift) stop() {
+	t.WriteByte(0)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"hn/hn"
+	"plugin"
+)
+
+// loadPlugin opens a Go plugin that processes posts. It must export
+//
+//	func Process(post hn.Post) (hn.Post, bool)
+//
+// and be built with the same version of Go and of this package, with
+// go build -buildmode=plugin. Plugins are only supported on Linux, macOS and FreeBSD.
+func loadPlugin(path string) (hn.Processor, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	symbol, err := p.Lookup("Process")
+	if err != nil {
+		return nil, err
+	}
+
+	process, ok := symbol.(func(hn.Post) (hn.Post, bool))
+	if !ok {
+		return nil, fmt.Errorf("%s exports Process as %T, it must be func(hn.Post) (hn.Post, bool)", path, symbol)
+	}
+
+	return process, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"hn/hn"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func runRead(args []string) error {
+	var newPosts bool
+	var textFormat string
+	var timeout time.Duration
+	var page bool
+
+	flags := flag.NewFlagSet("read", flag.ContinueOnError)
+	flags.BoolVar(&newPosts, "new", false, "Look up the rank in newest as opposed to front page (default false)")
+	flags.StringVar(&textFormat, "text-format", hn.TextMarkdown, "Format of the article, markdown, plain or html")
+	flags.DurationVar(&timeout, "timeout", 15*time.Second, "How long to wait for the page of the story")
+	flags.BoolVar(&page, "pager", true, "Page the article with $PAGER, or less, when writing to a terminal")
+
+	clientOptions := addClientFlags(flags)
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if err := clientOptions.apply(); err != nil {
+		return err
+	}
+
+	if err := hn.ValidTextFormat(textFormat); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("usage: hn read [-new] [-text-format=plain] <rank|id>")
+	}
+
+	n, err := strconv.Atoi(flags.Arg(0))
+	if err != nil || n < 1 {
+		return fmt.Errorf("%q is not a valid rank or id", flags.Arg(0))
+	}
+
+	post, err := findPost(n, newPosts)
+	if err != nil {
+		return err
+	}
+
+	var article *hn.Article
+	if getDomain(post.URL) == "" {
+		// Ask HN and other text posts are on HN, their text is the article
+		item, err := client.FetchItem(post.ID)
+		if err != nil {
+			return err
+		}
+		article = &hn.Article{Title: item.Title, Byline: item.Author, URL: client.ItemURL(item.ID), Content: item.Text}
+	} else {
+		// Stories are on other sites, fetched apart from the HN client and its rate
+		reader := hn.NewEnricher()
+		reader.HTTPClient.Timeout = timeout
+		reader.MaxBytes = 8 << 20
+
+		if article, err = reader.Read(post.URL); err != nil {
+			return err
+		}
+		if article.Title == "" {
+			article.Title = post.Title
+		}
+	}
+
+	return writePaged(renderArticle(article, textFormat), page)
+}
+
+// renderArticle puts the title, byline and url above the text of the article
+func renderArticle(article *hn.Article, textFormat string) string {
+	if textFormat == hn.TextHTML {
+		return article.Content
+	}
+
+	var b strings.Builder
+	if textFormat == hn.TextMarkdown {
+		b.WriteString("# " + article.Title + "\n\n")
+	} else {
+		b.WriteString(article.Title + "\n\n")
+	}
+
+	about := make([]string, 0, 2)
+	for _, s := range []string{article.Byline, article.SiteName} {
+		if s != "" {
+			about = append(about, s)
+		}
+	}
+	if len(about) > 0 {
+		b.WriteString(strings.Join(about, ", ") + "\n")
+	}
+	b.WriteString(article.URL + "\n\n")
+
+	b.WriteString(hn.ConvertText(article.Content, textFormat))
+	return b.String()
+}
+
+// writePaged writes text through the pager when writing to a terminal, or as
+// is when there is no pager
+func writePaged(text string, page bool) error {
+	if !page || !isTerminal(os.Stdout) {
+		_, err := fmt.Println(text)
+		return err
+	}
+
+	pager := strings.Fields(os.Getenv("PAGER"))
+	if len(pager) == 0 {
+		pager = []string{"less"}
+	}
+
+	cmd := exec.Command(pager[0], pager[1:]...)
+	cmd.Stdin = strings.NewReader(text + "\n")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+	if errors.Is(err, exec.ErrNotFound) {
+		_, err = fmt.Println(text)
+	}
+	return err
+}
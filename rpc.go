@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hn/hn"
+	"io"
+	"net/textproto"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcServerError    = -32000
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// rpcParams are shared by every method, each only reads the ones it needs
+type rpcParams struct {
+	Posts      int     `json:"posts"`
+	New        bool    `json:"new"`
+	Query      string  `json:"query"`
+	ID         itemRef `json:"id"`
+	Comments   bool    `json:"comments"`
+	Match      string  `json:"match"`
+	Top        int     `json:"top"`
+	MaxDepth   int     `json:"maxDepth"`
+	Flat       bool    `json:"flat"`
+	TextFormat string  `json:"textFormat"`
+}
+
+// itemRef is an item id or url, editors may send either
+type itemRef string
+
+func (r *itemRef) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*r = itemRef(s)
+		return nil
+	}
+
+	var id int
+	if err := json.Unmarshal(data, &id); err != nil {
+		return errors.New("id must be an item id or url")
+	}
+
+	*r = itemRef(strconv.Itoa(id))
+	return nil
+}
+
+type rpcMethod func(params rpcParams) (interface{}, error)
+
+var rpcMethods = map[string]rpcMethod{
+	"list":     rpcList,
+	"search":   rpcSearch,
+	"item":     rpcItem,
+	"comments": rpcComments,
+}
+
+func runRPC(args []string) error {
+	var stdio bool
+
+	flags := flag.NewFlagSet("lsp-ish", flag.ContinueOnError)
+	flags.BoolVar(&stdio, "stdio", false, "Serve JSON-RPC over stdin and stdout, framed with Content-Length headers like LSP")
+
+	clientOptions := addClientFlags(flags)
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if err := clientOptions.apply(); err != nil {
+		return err
+	}
+
+	if !stdio {
+		return errors.New("usage: hn lsp-ish -stdio, stdio is the only transport")
+	}
+
+	return serveRPC(os.Stdin, os.Stdout)
+}
+
+// serveRPC handles requests until the input ends or the client sends exit.
+// Requests are handled concurrently so a slow fetch does not block the editor.
+func serveRPC(r io.Reader, w io.Writer) error {
+	reader := textproto.NewReader(bufio.NewReader(r))
+	var mutex sync.Mutex
+	var wait sync.WaitGroup
+	defer wait.Wait()
+
+	respond := func(response rpcResponse) {
+		body, err := json.Marshal(response)
+		if err != nil {
+			return
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		fmt.Fprintf(w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+
+	for {
+		body, err := readRPCMessage(reader)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		var request rpcRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			respond(rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{rpcParseError, err.Error()}})
+			continue
+		}
+
+		if request.Method == "exit" {
+			return nil
+		}
+
+		wait.Add(1)
+		go func(request rpcRequest) {
+			defer wait.Done()
+
+			result, rpcErr := handleRPC(request)
+
+			// Notifications, requests without an id, do not get a response
+			if len(request.ID) == 0 {
+				return
+			}
+
+			respond(rpcResponse{JSONRPC: "2.0", ID: request.ID, Result: result, Error: rpcErr})
+		}(request)
+	}
+}
+
+func handleRPC(request rpcRequest) (interface{}, *rpcError) {
+	if request.JSONRPC != "2.0" || request.Method == "" {
+		return nil, &rpcError{rpcInvalidRequest, "invalid request"}
+	}
+
+	method, ok := rpcMethods[request.Method]
+	if !ok {
+		return nil, &rpcError{rpcMethodNotFound, "method not found: " + request.Method}
+	}
+
+	params := rpcParams{Posts: 30, TextFormat: hn.TextMarkdown}
+	if len(request.Params) > 0 {
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			return nil, &rpcError{rpcInvalidParams, err.Error()}
+		}
+	}
+
+	if err := hn.ValidTextFormat(params.TextFormat); err != nil {
+		return nil, &rpcError{rpcInvalidParams, err.Error()}
+	}
+
+	result, err := method(params)
+	if err != nil {
+		return nil, &rpcError{rpcServerError, err.Error()}
+	}
+
+	return result, nil
+}
+
+// readRPCMessage reads the headers and then the body of a message
+func readRPCMessage(reader *textproto.Reader) ([]byte, error) {
+	header, err := reader.ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF && len(header) == 0 {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, errors.New("message does not have a valid Content-Length header")
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(reader.R, body); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSpace(body), nil
+}
+
+func rpcList(params rpcParams) (interface{}, error) {
+	if params.Posts < 1 || params.Posts > 100 {
+		return nil, errors.New("posts must be between 1 and 100, inclusive")
+	}
+
+	return client.FetchPosts(listSection(params.New), params.Posts)
+}
+
+// rpcSearch lists the posts whose titles match the query, a regular expression
+func rpcSearch(params rpcParams) (interface{}, error) {
+	re, err := regexp.Compile(params.Query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query: %v", err)
+	}
+
+	result, err := rpcList(params)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make(hn.Posts, 0)
+	for _, post := range result.(hn.Posts) {
+		if re.MatchString(post.Title) {
+			matches = append(matches, post)
+		}
+	}
+
+	return matches, nil
+}
+
+func rpcItem(params rpcParams) (interface{}, error) {
+	id, err := hn.ParseItemID(strings.TrimSpace(string(params.ID)))
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := client.FetchItem(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !params.Comments {
+		item.Replies = nil
+	}
+	hn.FormatItemText(item, params.TextFormat)
+
+	return item, nil
+}
+
+func rpcComments(params rpcParams) (interface{}, error) {
+	id, err := hn.ParseItemID(strings.TrimSpace(string(params.ID)))
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := client.FetchItem(id)
+	if err != nil {
+		return nil, err
+	}
+
+	options := commentOptions{
+		top:        params.Top,
+		maxDepth:   params.MaxDepth,
+		flat:       params.Flat,
+		textFormat: params.TextFormat,
+	}
+	if params.Match != "" {
+		options.match, err = regexp.Compile(params.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid match: %v", err)
+		}
+	}
+
+	return selectComments(item.Replies, options), nil
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hn/hn"
+	"io"
+	"log/slog"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A jsonSchema is the part of JSON Schema needed to describe the output, and
+// to validate it
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	AdditionalProperties *jsonSchema            `json:"additionalProperties,omitempty"`
+	AnyOf                []*jsonSchema          `json:"anyOf,omitempty"`
+	Defs                 map[string]*jsonSchema `json:"$defs,omitempty"`
+}
+
+// schemaKinds are the JSON outputs with a schema, by the name given to hn schema
+var schemaKinds = map[string]func(g *schemaGenerator) *jsonSchema{
+	"posts": func(g *schemaGenerator) *jsonSchema {
+		return g.schema(reflect.TypeOf(hn.Posts{}))
+	},
+	"item": func(g *schemaGenerator) *jsonSchema {
+		return g.schema(reflect.TypeOf(hn.Item{}))
+	},
+	// A tree, or a flat list with -flat
+	"comments": func(g *schemaGenerator) *jsonSchema {
+		return &jsonSchema{AnyOf: []*jsonSchema{
+			g.schema(reflect.TypeOf([]*hn.Comment{})),
+			g.schema(reflect.TypeOf([]FlatComment{})),
+		}}
+	},
+	"envelope": func(g *schemaGenerator) *jsonSchema {
+		envelope := g.schema(reflect.TypeOf(Envelope{}))
+
+		// Posts are a list, keyed by section with -by-section, of posts or of the fields selected with -fields
+		posts := &jsonSchema{AnyOf: []*jsonSchema{
+			g.schema(reflect.TypeOf(hn.Posts{})),
+			{Type: "array", Items: &jsonSchema{Type: "object"}},
+		}}
+		g.defs["Envelope"].Properties["Posts"] = &jsonSchema{
+			Description: "The posts, keyed by section with -by-section, with only the fields selected with -fields",
+			AnyOf:       []*jsonSchema{posts, {Type: "object", AdditionalProperties: posts}},
+		}
+
+		return envelope
+	},
+}
+
+func runSchema(args []string) error {
+	var validate bool
+
+	flags := flag.NewFlagSet("schema", flag.ContinueOnError)
+	flags.BoolVar(&validate, "validate", false, "Validate JSON, or lines of JSON, read from the file or stdin against the schema rather than printing it")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	usage := errors.New("usage: hn schema [-validate] <posts|item|comments|envelope> [file]")
+	if flags.NArg() < 1 || flags.NArg() > 2 || (flags.NArg() == 2 && !validate) {
+		return usage
+	}
+
+	schema, ok := newSchema(flags.Arg(0))
+	if !ok {
+		return usage
+	}
+
+	if !validate {
+		return writeIndentedJSON(schema)
+	}
+
+	in := io.Reader(os.Stdin)
+	if flags.NArg() == 2 {
+		f, err := os.Open(flags.Arg(1))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	return validateJSON(in, schema)
+}
+
+// newSchema is the schema of a kind of output, false if there is no such kind
+func newSchema(name string) (*jsonSchema, bool) {
+	kind, ok := schemaKinds[name]
+	if !ok {
+		return nil, false
+	}
+
+	g := &schemaGenerator{defs: make(map[string]*jsonSchema)}
+	schema := kind(g)
+	schema.Schema = "https://json-schema.org/draft/2020-12/schema"
+	schema.Title = "hn " + name
+	schema.Defs = g.defs
+	return schema, true
+}
+
+// validateJSON validates every JSON value read, logging each problem
+func validateJSON(r io.Reader, schema *jsonSchema) error {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+
+	problems := 0
+	for n := 1; ; n++ {
+		var value interface{}
+		err := decoder.Decode(&value)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading value %d: %v", n, err)
+		}
+
+		for _, problem := range validateSchema(schema, schema, value, "$") {
+			slog.Warn("value does not match the schema", "value", n, "problem", problem)
+			problems++
+		}
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("%d problems, the JSON does not match the schema", problems)
+	}
+	return nil
+}
+
+// A schemaGenerator describes Go types as they are marshalled by
+// encoding/json, with each struct defined once in defs
+type schemaGenerator struct {
+	defs map[string]*jsonSchema
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (g *schemaGenerator) schema(t reflect.Type) *jsonSchema {
+	if t == timeType {
+		return &jsonSchema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.schema(t.Elem())
+	case reflect.Slice, reflect.Array:
+		return &jsonSchema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &jsonSchema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		return g.object(t)
+	case reflect.Interface:
+		return &jsonSchema{}
+	case reflect.String:
+		return &jsonSchema{Type: "string"}
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &jsonSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &jsonSchema{Type: "number"}
+	}
+
+	return &jsonSchema{}
+}
+
+// object defines a struct by its fields, those of embedded structs included,
+// every field without omitempty is required
+func (g *schemaGenerator) object(t reflect.Type) *jsonSchema {
+	ref := &jsonSchema{Ref: "#/$defs/" + t.Name()}
+	if _, ok := g.defs[t.Name()]; ok {
+		return ref
+	}
+
+	// Defined before its fields, as comments refer to themselves
+	object := &jsonSchema{Type: "object", Properties: make(map[string]*jsonSchema)}
+	g.defs[t.Name()] = object
+
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || (field.Anonymous && field.Type.Kind() == reflect.Struct) {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := g.schema(field.Type)
+		omitEmpty := strings.Contains(options, "omitempty")
+		if !omitEmpty {
+			object.Required = append(object.Required, name)
+		}
+
+		// Nil pointers, slices and maps are written as null, unless they are left out
+		switch field.Type.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map:
+			if !omitEmpty {
+				property = &jsonSchema{AnyOf: []*jsonSchema{property, {Type: "null"}}}
+			}
+		}
+
+		object.Properties[name] = property
+	}
+
+	return ref
+}
+
+// validateSchema returns where value does not match schema, refs are looked up in the defs of root
+func validateSchema(root *jsonSchema, schema *jsonSchema, value interface{}, path string) []string {
+	if schema.Ref != "" {
+		def, ok := root.Defs[strings.TrimPrefix(schema.Ref, "#/$defs/")]
+		if !ok {
+			return []string{fmt.Sprintf("%s: unknown $ref %s", path, schema.Ref)}
+		}
+		return validateSchema(root, def, value, path)
+	}
+
+	if len(schema.AnyOf) > 0 {
+		var first []string
+		for i, option := range schema.AnyOf {
+			problems := validateSchema(root, option, value, path)
+			if len(problems) == 0 {
+				return nil
+			}
+			if i == 0 {
+				first = problems
+			}
+		}
+		// The first option is the usual one, its problems say the most
+		return first
+	}
+
+	if valueType := jsonType(value); schema.Type != "" && schema.Type != valueType && !(schema.Type == "number" && valueType == "integer") {
+		return []string{fmt.Sprintf("%s: is %s, not %s", path, valueType, schema.Type)}
+	}
+
+	problems := make([]string, 0)
+	switch v := value.(type) {
+	case string:
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, v); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %q is not a date-time", path, v))
+			}
+		}
+	case []interface{}:
+		if schema.Items != nil {
+			for i, item := range v {
+				problems = append(problems, validateSchema(root, schema.Items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range schema.Required {
+			if _, ok := v[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: %s is missing", path, name))
+			}
+		}
+
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			property, ok := schema.Properties[name]
+			if !ok {
+				property = schema.AdditionalProperties
+			}
+			if property != nil {
+				problems = append(problems, validateSchema(root, property, v[name], path+"."+name)...)
+			}
+		}
+	}
+
+	return problems
+}
+
+// jsonType is the JSON Schema type of a value decoded with UseNumber
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "unknown"
+}
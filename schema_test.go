@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"hn/hn"
+	"testing"
+	"time"
+)
+
+// testPosts have every field set, optional ones too, so a field the schema
+// does not know of is caught
+func testPosts() hn.Posts {
+	posted := time.Date(2020, 1, 1, 9, 0, 0, 0, time.UTC)
+	return hn.Posts{
+		{
+			ID: 1, Title: "Show HN: A story", URL: "https://example.com/", Author: "someone",
+			Points: 42, Comments: 7, Rank: 1, Time: posted,
+			Section: hn.SectionTop, SecondChance: true,
+			Sections:   []hn.Listing{{Section: hn.SectionTop, Rank: 1}, {Section: hn.SectionShow, Rank: 3}},
+			Preview:    &hn.Preview{Title: "A story", Description: "About it", Image: "https://example.com/a.png", SiteName: "Example", Favicon: "https://example.com/favicon.ico"},
+			Link:       &hn.LinkStatus{Status: 200, FinalURL: "https://example.com/", Paywalled: true},
+			Archive:    &hn.ArchiveStatus{Snapshot: "https://web.archive.org/web/2020/https://example.com/", JobID: "job"},
+			TopComment: &hn.TopComment{ID: 2, Author: "other", Text: "Nice"},
+		},
+		// An advertisement has no author, points or comments
+		{ID: 3, Title: "Acme (YC S24) is hiring", URL: "https://acme.example/jobs", Author: "N/A", Points: -1, Comments: -1, Rank: 2, Time: posted},
+	}
+}
+
+func testComments() []*hn.Comment {
+	commented := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	return []*hn.Comment{
+		{
+			ID: 2, Author: "other", Time: commented, Text: "Nice", Position: 1, Color: "c00", EstimatedScoreBand: hn.ScoreBand("c00"),
+			Replies: []*hn.Comment{
+				{ID: 4, Author: "someone", Time: commented, Text: "Thanks", Position: 1, Color: "c5a", EstimatedScoreBand: hn.ScoreBand("c5a")},
+			},
+		},
+	}
+}
+
+func TestOutputMatchesSchema(t *testing.T) {
+	posts := testPosts()
+	item := &hn.Item{Post: posts[0], Text: "Some *text*", Replies: testComments()}
+	fetched := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		kind  string
+		value interface{}
+	}{
+		{"posts", "posts", posts},
+		{"empty posts", "posts", hn.Posts{}},
+		{"item", "item", item},
+		{"item without comments", "item", &hn.Item{Post: posts[1]}},
+		{"comments", "comments", testComments()},
+		{"flat comments", "comments", flattenComments(testComments(), item.ID, 0, nil)},
+		{"envelope", "envelope", newEnvelope([]string{hn.SectionTop}, 1, 30, fetched, posts, nil)},
+		{"envelope with fields", "envelope", newEnvelope([]string{hn.SectionTop}, 1, 30, fetched, posts, []string{"ID", "Title", "Points"})},
+		{"envelope by section", "envelope", Envelope{
+			SchemaVersion: schemaVersion,
+			FetchedAt:     fetched,
+			Sections:      []string{hn.SectionTop, hn.SectionShow},
+			Pages:         2,
+			Warnings:      []string{},
+			Posts:         hn.SplitSections([]string{hn.SectionTop, hn.SectionShow}, posts),
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schema, ok := newSchema(test.kind)
+			if !ok {
+				t.Fatalf("no schema for %q", test.kind)
+			}
+
+			data, err := json.Marshal(test.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := validateJSON(bytes.NewReader(data), schema); err != nil {
+				t.Errorf("%v\n%s", err, data)
+			}
+		})
+	}
+}
+
+func TestSchemaRejectsOtherJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		kind string
+		json string
+	}{
+		{"missing field", "posts", `[{"ID": 1, "Title": "A story"}]`},
+		{"wrong type", "item", `{"ID": "1", "Title": "", "URL": "", "Author": "", "Points": 0, "Comments": 0, "Rank": 0, "Time": "2020-01-01T00:00:00Z", "Text": ""}`},
+		{"not a list", "comments", `{"ID": 1}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schema, _ := newSchema(test.kind)
+			if err := validateJSON(bytes.NewReader([]byte(test.json)), schema); err == nil {
+				t.Errorf("%s validated against the %s schema", test.json, test.kind)
+			}
+		})
+	}
+}
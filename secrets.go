@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Secret providers read a value, such as a sink URL with a password or a
+// webhook, from somewhere other than the command line or a config file, where
+// it would be visible to other users or end up in version control.
+var secretProviders = map[string]func(name string) (string, error){
+	"env":      readEnvSecret,
+	"file":     readFileSecret,
+	"cred":     readCredentialSecret,
+	"keychain": readKeychainSecret,
+}
+
+// resolveSecret returns the secret a value refers to, such as env:HN_SINK,
+// file:/run/secrets/hn-sink, cred:hn-sink or keychain:hn-sink, otherwise the value itself
+func resolveSecret(value string) (string, error) {
+	i := strings.Index(value, ":")
+	if i < 0 {
+		return value, nil
+	}
+
+	read, ok := secretProviders[value[:i]]
+	if !ok {
+		return value, nil
+	}
+
+	secret, err := read(value[i+1:])
+	if err != nil {
+		return "", fmt.Errorf("reading secret %s: %v", value, err)
+	}
+
+	// Files and commands usually end with a new line, which is never part of the secret
+	return strings.TrimRight(secret, "\r\n"), nil
+}
+
+// isSecret is true for a value that refers to a secret rather than holding it
+func isSecret(value string) bool {
+	i := strings.Index(value, ":")
+	if i < 0 {
+		return false
+	}
+
+	_, ok := secretProviders[value[:i]]
+	return ok
+}
+
+// getenvSecret reads an environment variable or, following the convention of
+// Docker secrets, the file named by the same variable with a _FILE suffix
+func getenvSecret(name string) (string, error) {
+	if path := os.Getenv(name + "_FILE"); path != "" {
+		secret, err := readFileSecret(path)
+		if err != nil {
+			return "", fmt.Errorf("reading secret %s_FILE: %v", name, err)
+		}
+		return strings.TrimRight(secret, "\r\n"), nil
+	}
+
+	return os.Getenv(name), nil
+}
+
+func readEnvSecret(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", errors.New("environment variable is not set")
+	}
+	return value, nil
+}
+
+func readFileSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// readCredentialSecret reads a systemd credential, passed to the service with LoadCredential=
+func readCredentialSecret(name string) (string, error) {
+	dir := os.Getenv("CREDENTIALS_DIRECTORY")
+	if dir == "" {
+		return "", errors.New("CREDENTIALS_DIRECTORY is not set, is hn running as a systemd service with LoadCredential=?")
+	}
+
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return "", errors.New("credential name must not be empty or contain a path")
+	}
+
+	return readFileSecret(filepath.Join(dir, name))
+}
+
+// readKeychainSecret reads a password stored under a service, and optionally
+// an account as service/account, from the macOS keychain or the Secret Service
+// on Linux, e.g. as added by `secret-tool store --label=hn service hn-sink`
+func readKeychainSecret(name string) (string, error) {
+	service, account := name, ""
+	if i := strings.Index(name, "/"); i >= 0 {
+		service, account = name[:i], name[i+1:]
+	}
+
+	if service == "" {
+		return "", errors.New("keychain secrets need a service, e.g. keychain:hn-sink")
+	}
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		args := []string{"find-generic-password", "-w", "-s", service}
+		if account != "" {
+			args = append(args, "-a", account)
+		}
+		cmd = exec.Command("security", args...)
+	case "linux", "freebsd", "openbsd", "netbsd":
+		args := []string{"lookup", "service", service}
+		if account != "" {
+			args = append(args, "account", account)
+		}
+		cmd = exec.Command("secret-tool", args...)
+	default:
+		return "", fmt.Errorf("keychains are not supported on %s", runtime.GOOS)
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("%v: %s", err, message)
+		}
+		return "", err
+	}
+
+	if len(out) == 0 {
+		return "", errors.New("secret was not found")
+	}
+
+	return string(out), nil
+}
+
+// redactURL hides the password of a URL, so errors and logs do not leak it
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		if err != nil {
+			return "<invalid url>"
+		}
+		return value
+	}
+
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	}
+	return u.String()
+}
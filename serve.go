@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"hn/hn"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// server serves posts, items and, given a store, snapshots as JSON
+type server struct {
+	store  store
+	stream *broadcaster
+}
+
+func runServe(args []string) error {
+	var listen string
+	var storeURL string
+	var streamEvery time.Duration
+	var streamPosts int
+	var streamSection string
+
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	flags.StringVar(&listen, "listen", "localhost:8080", "Address to serve the API on")
+	flags.StringVar(&storeURL, "store", "", "Serve the snapshots in a store, e.g. file:///var/lib/hn")
+	flags.DurationVar(&streamEvery, "stream-every", time.Minute, "How often to poll for the changes sent to /stream, 0 disables it")
+	flags.IntVar(&streamPosts, "stream-posts", 30, "How many posts to poll for /stream. A positive integer <= 100.")
+	flags.StringVar(&streamSection, "stream-section", "top", "Section to poll for /stream")
+
+	clientOptions := addClientFlags(flags)
+	clientOptions.inMemory = true
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if err := clientOptions.apply(); err != nil {
+		return err
+	}
+
+	if streamEvery < 0 {
+		return errors.New("stream-every must not be negative")
+	}
+
+	if streamPosts < 1 || streamPosts > 100 {
+		return errors.New("stream-posts must be between 1 and 100, inclusive")
+	}
+
+	section, err := getSection(streamSection)
+	if err != nil {
+		return err
+	}
+
+	s := &server{}
+	if storeURL != "" {
+		s.store, err = openStore(storeURL)
+		if err != nil {
+			return err
+		}
+	}
+
+	if streamEvery > 0 {
+		s.stream = newBroadcaster()
+		go s.stream.poll(section, streamPosts, streamEvery)
+	}
+
+	slog.Info("serving", "url", "http://"+listen)
+	return http.ListenAndServe(listen, s.handler())
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/posts", s.handlePosts)
+	mux.HandleFunc("/items/", s.handleItem)
+	mux.HandleFunc("/snapshots", s.handleSnapshots)
+	mux.HandleFunc("/snapshots/", s.handleSnapshot)
+	mux.HandleFunc("/graphql", s.handleGraphQL)
+	mux.HandleFunc("/stream", s.handleStream)
+	return mux
+}
+
+// handlePosts lists a section, e.g. /posts?section=best&posts=60
+func (s *server) handlePosts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	name := query.Get("section")
+	if name == "" {
+		name = "top"
+	}
+
+	section, err := getSection(name)
+	if err != nil {
+		writeHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	postsToFetch := 30
+	if value := query.Get("posts"); value != "" {
+		postsToFetch, err = strconv.Atoi(value)
+		if err != nil || postsToFetch < 1 || postsToFetch > 100 {
+			writeHTTPError(w, http.StatusBadRequest, errors.New("posts must be between 1 and 100, inclusive"))
+			return
+		}
+	}
+
+	posts, err := client.FetchPosts(section, postsToFetch)
+	if err != nil {
+		writeHTTPError(w, http.StatusBadGateway, err)
+		return
+	}
+
+	writeHTTPJSON(w, posts)
+}
+
+// handleItem returns an item with its comments, e.g. /items/8863
+func (s *server) handleItem(w http.ResponseWriter, r *http.Request) {
+	id, err := hn.ParseItemID(strings.TrimPrefix(r.URL.Path, "/items/"))
+	if err != nil {
+		writeHTTPError(w, http.StatusNotFound, err)
+		return
+	}
+
+	textFormat := r.URL.Query().Get("textFormat")
+	if textFormat == "" {
+		textFormat = hn.TextMarkdown
+	}
+
+	if err := hn.ValidTextFormat(textFormat); err != nil {
+		writeHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	item, err := client.FetchItem(id)
+	if err != nil {
+		writeHTTPError(w, http.StatusBadGateway, err)
+		return
+	}
+	hn.FormatItemText(item, textFormat)
+
+	writeHTTPJSON(w, item)
+}
+
+// handleSnapshots lists the snapshots in the store, e.g. /snapshots?section=top
+func (s *server) handleSnapshots(w http.ResponseWriter, r *http.Request) {
+	if s.store == nil {
+		writeHTTPError(w, http.StatusNotFound, errors.New("no store is configured"))
+		return
+	}
+
+	section := ""
+	if name := r.URL.Query().Get("section"); name != "" {
+		var err error
+		section, err = getSection(name)
+		if err != nil {
+			writeHTTPError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	snapshots, err := s.store.List(section)
+	if err != nil {
+		writeHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeHTTPJSON(w, snapshots)
+}
+
+// handleSnapshot returns a snapshot with its posts, e.g. /snapshots/news-20170102T150405Z
+func (s *server) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	if s.store == nil {
+		writeHTTPError(w, http.StatusNotFound, errors.New("no store is configured"))
+		return
+	}
+
+	snapshot, err := s.store.Load(strings.TrimPrefix(r.URL.Path, "/snapshots/"))
+	if err == errSnapshotNotFound {
+		writeHTTPError(w, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		writeHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeHTTPJSON(w, snapshot)
+}
+
+func writeHTTPJSON(w http.ResponseWriter, v interface{}) {
+	response, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		writeHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(response, '\n'))
+}
+
+func writeHTTPError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
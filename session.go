@@ -0,0 +1,213 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"hn/hn"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sessionPath is where hn login keeps the session, next to the config
+func sessionPath() (string, error) {
+	path, _ := configPath()
+	if path == "" {
+		return "", errors.New("there is no config directory, set HN_CONFIG")
+	}
+	return filepath.Join(filepath.Dir(path), "session"), nil
+}
+
+// useSession makes the client act as the logged in user, from hn login or by
+// logging in as HN_USER with HN_PASSWORD
+func useSession() error {
+	path, err := sessionPath()
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if err == nil {
+		client.Session = strings.TrimSpace(string(data))
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	user, err := getenvSecret("HN_USER")
+	if err != nil {
+		return err
+	}
+
+	password, err := getenvSecret("HN_PASSWORD")
+	if err != nil {
+		return err
+	}
+
+	if user == "" || password == "" {
+		return errors.New("not logged in, run hn login or set HN_USER and HN_PASSWORD")
+	}
+
+	return client.Login(user, password)
+}
+
+func runLogin(args []string) error {
+	var user string
+	var password string
+
+	flags := flag.NewFlagSet("login", flag.ContinueOnError)
+	flags.StringVar(&user, "user", "", "User to log in as (default $HN_USER)")
+	flags.StringVar(&password, "password", "", "A secret with the password, e.g. env:NAME, file:/path, cred:name or keychain:service (default $HN_PASSWORD)")
+
+	clientOptions := addClientFlags(flags)
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if err := clientOptions.apply(); err != nil {
+		return err
+	}
+
+	if user == "" {
+		if user, err = getenvSecret("HN_USER"); err != nil {
+			return err
+		}
+	}
+
+	// The password is never taken as is, it would end up in the shell history
+	if password != "" {
+		if !isSecret(password) {
+			return errors.New("-password must name a secret holding the password, e.g. env:NAME or keychain:hn, not the password itself")
+		}
+		if password, err = resolveSecret(password); err != nil {
+			return err
+		}
+	} else if password, err = getenvSecret("HN_PASSWORD"); err != nil {
+		return err
+	}
+
+	if user == "" || password == "" {
+		return errors.New("usage: hn login -user=name -password=keychain:hn, or set HN_USER and HN_PASSWORD")
+	}
+
+	if err := client.Login(user, password); err != nil {
+		return err
+	}
+
+	path, err := sessionPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	// The session is as good as the password, so only the user can read it
+	if err := os.WriteFile(path, []byte(client.Session+"\n"), 0600); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Logged in as %s, the session is in %s\n", client.User(), path)
+	return nil
+}
+
+func runLogout(args []string) error {
+	path, err := sessionPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func runSubmit(args []string) error {
+	var title string
+	var link string
+	var text string
+
+	flags := flag.NewFlagSet("submit", flag.ContinueOnError)
+	flags.StringVar(&title, "title", "", "Title of the story")
+	flags.StringVar(&link, "url", "", "Link of the story, or leave it out and give text, e.g. for an Ask HN")
+	flags.StringVar(&text, "text", "", "Text of the story")
+
+	clientOptions := addClientFlags(flags)
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if err := clientOptions.apply(); err != nil {
+		return err
+	}
+
+	if title == "" || link == "" && text == "" {
+		return errors.New(`usage: hn submit -title="..." -url="..." or -text="..."`)
+	}
+
+	if err := useSession(); err != nil {
+		return err
+	}
+
+	post, err := client.Submit(title, link, text)
+	if err != nil {
+		return err
+	}
+
+	return writeIndentedJSON(post)
+}
+
+func runReply(args []string) error {
+	var text string
+
+	flags := flag.NewFlagSet("reply", flag.ContinueOnError)
+	flags.StringVar(&text, "text", "", "Text of the reply")
+
+	clientOptions := addClientFlags(flags)
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	// Flags stop at the first argument, so those after the id, as in hn reply 123 -text="...", are parsed next
+	positional := flags.Args()
+	if len(positional) > 1 {
+		if err := flags.Parse(positional[1:]); err != nil {
+			return err
+		}
+		positional = append(positional[:1], flags.Args()...)
+	}
+
+	if err := clientOptions.apply(); err != nil {
+		return err
+	}
+
+	if len(positional) != 1 || text == "" {
+		return errors.New(`usage: hn reply <id> -text="..."`)
+	}
+
+	id, err := hn.ParseItemID(positional[0])
+	if err != nil {
+		return err
+	}
+
+	if err := useSession(); err != nil {
+		return err
+	}
+
+	comment, err := client.Reply(id, text)
+	if err != nil {
+		return err
+	}
+
+	return writeIndentedJSON(comment)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hn/hn"
+	"io"
+	"strings"
+)
+
+// statusBar is the JSON Waybar expects from a custom module, i3status and
+// polybar wrappers read the same text field
+type statusBar struct {
+	Text    string `json:"text"`
+	Tooltip string `json:"tooltip"`
+	Class   string `json:"class"`
+}
+
+// statusBarTextLength keeps the top story short enough to fit a bar
+const statusBarTextLength = 60
+
+func writeStatusBar(w io.Writer, posts hn.Posts) error {
+	status := statusBar{Text: "HN", Class: "empty"}
+
+	if len(posts) > 0 {
+		top := posts[0]
+		status.Text = truncate(top.Title, statusBarTextLength)
+		status.Class = pointsClass(top.Points)
+
+		lines := make([]string, len(posts))
+		for i, post := range posts {
+			lines[i] = fmt.Sprintf("%d. %s (%s points)", post.Rank, post.Title, countString(post.Points))
+		}
+		status.Tooltip = strings.Join(lines, "\n")
+	}
+
+	return encodeStatusBar(w, status)
+}
+
+// writeAlertStatusBar summarises the alerts of the latest watch poll
+func writeAlertStatusBar(w io.Writer, events []Event) error {
+	status := statusBar{Text: "HN 0", Class: "idle"}
+
+	if len(events) > 0 {
+		status.Text = fmt.Sprintf("HN %d", len(events))
+		status.Class = "alert"
+
+		lines := make([]string, len(events))
+		for i, event := range events {
+			lines[i] = strings.SplitN(eventMessage(event), "\n", 2)[0]
+		}
+		status.Tooltip = strings.Join(lines, "\n")
+	}
+
+	return encodeStatusBar(w, status)
+}
+
+func encodeStatusBar(w io.Writer, status statusBar) error {
+	// Bars show the text as is, so keep characters such as & readable
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(status)
+}
+
+// pointsClass matches the thresholds of the colors in human output
+func pointsClass(points int) string {
+	if points > 500 {
+		return "hot"
+	} else if points > 100 {
+		return "popular"
+	}
+	return "normal"
+}
+
+// truncate shortens text to at most length runes, marking that it was cut
+func truncate(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+	return string(runes[:length-1]) + "…"
+}
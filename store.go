@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hn/hn"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A Snapshot is a section as it was listed at a point in time
+type Snapshot struct {
+	ID      string
+	Section string
+	Time    time.Time
+	Posts   hn.Posts `json:",omitempty"`
+}
+
+// snapshotTimeFormat sorts snapshots of a section by time when sorted by id
+const snapshotTimeFormat = "20060102T150405Z"
+
+func snapshotID(section string, t time.Time) string {
+	return section + "-" + t.UTC().Format(snapshotTimeFormat)
+}
+
+// A store keeps snapshots
+type store interface {
+	// Save stores a snapshot, replacing one with the same id
+	Save(snapshot Snapshot) error
+	// List returns the snapshots of a section, or every section, oldest first and without posts
+	List(section string) ([]Snapshot, error)
+	// Load returns a snapshot with its posts
+	Load(id string) (*Snapshot, error)
+	// Prune removes the snapshots of a section, or every section, taken before a time, returning how many were removed
+	Prune(section string, before time.Time) (int, error)
+
+	// LoadUser returns what is known of a tracked user, nil if the user is not tracked yet
+	LoadUser(name string) (*UserHistory, error)
+	// SaveUser stores what is known of a tracked user
+	SaveUser(history UserHistory) error
+}
+
+var errSnapshotNotFound = errors.New("snapshot not found")
+
+// Stores are configured as URLs, the scheme picks the store. The SQLite store
+// is only built with -tags sqlite, see store_sqlite.go.
+var stores = map[string]func(u *url.URL) (store, error){
+	"file": newFileStore,
+}
+
+func openStore(value string) (store, error) {
+	u, err := url.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+
+	create, ok := stores[u.Scheme]
+	if !ok && u.Scheme == "sqlite" {
+		return nil, fmt.Errorf("store %q needs hn built with SQLite, go build -tags sqlite", value)
+	} else if !ok {
+		return nil, fmt.Errorf("unknown store %q, e.g. file:///var/lib/hn or sqlite:///var/lib/hn/hn.db", value)
+	}
+
+	return create(u)
+}
+
+// fileStore keeps each snapshot as a JSON file, in a directory per section,
+// and each tracked user as a JSON file in the users directory
+type fileStore struct {
+	dir string
+}
+
+func newFileStore(u *url.URL) (store, error) {
+	dir := u.Path
+	if dir == "" {
+		dir = u.Opaque
+	}
+
+	if dir == "" {
+		return nil, errors.New("file store requires a directory, e.g. file:///var/lib/hn")
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &fileStore{dir: dir}, nil
+}
+
+func (s *fileStore) path(id string) (string, error) {
+	i := strings.LastIndex(id, "-")
+	if i < 1 || strings.ContainsAny(id, `/\.`) {
+		return "", errSnapshotNotFound
+	}
+
+	return filepath.Join(s.dir, id[:i], id+".json"), nil
+}
+
+func (s *fileStore) Save(snapshot Snapshot) error {
+	path, err := s.path(snapshot.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	// Write then rename, so a snapshot is never read half written
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+func (s *fileStore) List(section string) ([]Snapshot, error) {
+	pattern := filepath.Join(s.dir, "*", "*.json")
+	if section != "" {
+		pattern = filepath.Join(s.dir, section, "*.json")
+	}
+
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]Snapshot, 0, len(paths))
+	for _, path := range paths {
+		if filepath.Base(filepath.Dir(path)) == fileStoreUsers {
+			continue
+		}
+
+		id := strings.TrimSuffix(filepath.Base(path), ".json")
+		i := strings.LastIndex(id, "-")
+		if i < 1 {
+			continue
+		}
+
+		t, err := time.Parse(snapshotTimeFormat, id[i+1:])
+		if err != nil {
+			continue
+		}
+
+		snapshots = append(snapshots, Snapshot{ID: id, Section: id[:i], Time: t})
+	}
+
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		if !snapshots[i].Time.Equal(snapshots[j].Time) {
+			return snapshots[i].Time.Before(snapshots[j].Time)
+		}
+		return snapshots[i].Section < snapshots[j].Section
+	})
+
+	return snapshots, nil
+}
+
+func (s *fileStore) Load(id string) (*Snapshot, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, errSnapshotNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	snapshot := &Snapshot{}
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+func (s *fileStore) Prune(section string, before time.Time) (int, error) {
+	snapshots, err := s.List(section)
+	if err != nil {
+		return 0, err
+	}
+
+	pruned := 0
+	for _, snapshot := range snapshots {
+		if !snapshot.Time.Before(before) {
+			continue
+		}
+
+		path, err := s.path(snapshot.ID)
+		if err != nil {
+			return pruned, err
+		}
+
+		if err := os.Remove(path); err != nil {
+			return pruned, err
+		}
+		pruned++
+	}
+
+	return pruned, nil
+}
+
+// fileStoreUsers is the directory of tracked users, which is not a section
+const fileStoreUsers = "users"
+
+func (s *fileStore) userPath(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\.`) {
+		return "", fmt.Errorf("invalid user %q", name)
+	}
+
+	return filepath.Join(s.dir, fileStoreUsers, name+".json"), nil
+}
+
+func (s *fileStore) LoadUser(name string) (*UserHistory, error) {
+	path, err := s.userPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	history := &UserHistory{}
+	if err := json.Unmarshal(data, history); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+func (s *fileStore) SaveUser(history UserHistory) error {
+	path, err := s.userPath(history.User)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
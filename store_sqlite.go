@@ -0,0 +1,183 @@
+//go:build sqlite
+
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	// A SQLite driver in pure Go, so hn still builds without cgo
+	_ "modernc.org/sqlite"
+)
+
+func init() {
+	stores["sqlite"] = newSQLiteStore
+}
+
+// sqliteSchema creates the tables of a new database, and leaves those of an
+// existing one be
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS snapshots (
+	id      TEXT PRIMARY KEY,
+	section TEXT NOT NULL,
+	time    INTEGER NOT NULL,
+	posts   TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS snapshots_section_time ON snapshots (section, time);
+CREATE TABLE IF NOT EXISTS users (
+	name    TEXT PRIMARY KEY,
+	history TEXT NOT NULL
+);`
+
+// sqliteStore keeps snapshots and tracked users in a SQLite database, a row
+// each, with the posts of a snapshot and the history of a user as JSON. Times
+// are unix nanoseconds, so they compare as numbers.
+type sqliteStore struct {
+	db *sql.DB
+}
+
+func newSQLiteStore(u *url.URL) (store, error) {
+	path := u.Path
+	if path == "" {
+		path = u.Opaque
+	}
+
+	if path == "" {
+		return nil, errors.New("sqlite store requires a database file, e.g. sqlite:///var/lib/hn/hn.db")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite has a single writer, the daemon and serve take turns on one connection
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("sqlite store %s: %w", path, err)
+	}
+
+	return &sqliteStore{db: db}, nil
+}
+
+func (s *sqliteStore) Save(snapshot Snapshot) error {
+	posts, err := json.Marshal(snapshot.Posts)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(
+		`INSERT OR REPLACE INTO snapshots (id, section, time, posts) VALUES (?, ?, ?, ?)`,
+		snapshot.ID, snapshot.Section, snapshot.Time.UnixNano(), string(posts),
+	)
+	return err
+}
+
+func (s *sqliteStore) List(section string) ([]Snapshot, error) {
+	query := `SELECT id, section, time FROM snapshots ORDER BY time, section`
+	args := []interface{}{}
+	if section != "" {
+		query = `SELECT id, section, time FROM snapshots WHERE section = ? ORDER BY time, section`
+		args = append(args, section)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := make([]Snapshot, 0)
+	for rows.Next() {
+		var snapshot Snapshot
+		var t int64
+		if err := rows.Scan(&snapshot.ID, &snapshot.Section, &t); err != nil {
+			return nil, err
+		}
+
+		snapshot.Time = time.Unix(0, t).UTC()
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, rows.Err()
+}
+
+func (s *sqliteStore) Load(id string) (*Snapshot, error) {
+	snapshot := &Snapshot{ID: id}
+	var t int64
+	var posts string
+
+	err := s.db.QueryRow(`SELECT section, time, posts FROM snapshots WHERE id = ?`, id).Scan(&snapshot.Section, &t, &posts)
+	if err == sql.ErrNoRows {
+		return nil, errSnapshotNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	snapshot.Time = time.Unix(0, t).UTC()
+	if err := json.Unmarshal([]byte(posts), &snapshot.Posts); err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+func (s *sqliteStore) Prune(section string, before time.Time) (int, error) {
+	query := `DELETE FROM snapshots WHERE time < ?`
+	args := []interface{}{before.UnixNano()}
+	if section != "" {
+		query = `DELETE FROM snapshots WHERE section = ? AND time < ?`
+		args = []interface{}{section, before.UnixNano()}
+	}
+
+	result, err := s.db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	pruned, err := result.RowsAffected()
+	return int(pruned), err
+}
+
+func (s *sqliteStore) LoadUser(name string) (*UserHistory, error) {
+	var data string
+	err := s.db.QueryRow(`SELECT history FROM users WHERE name = ?`, name).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	history := &UserHistory{}
+	if err := json.Unmarshal([]byte(data), history); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+func (s *sqliteStore) SaveUser(history UserHistory) error {
+	if history.User == "" {
+		return fmt.Errorf("invalid user %q", history.User)
+	}
+
+	data, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`INSERT OR REPLACE INTO users (name, history) VALUES (?, ?)`, history.User, string(data))
+	return err
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"hn/hn"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// forEachStore runs a test against an empty store of every kind built in
+func forEachStore(t *testing.T, test func(t *testing.T, s store)) {
+	for scheme, open := range stores {
+		t.Run(scheme, func(t *testing.T) {
+			s, err := open(&url.URL{Scheme: scheme, Path: filepath.Join(t.TempDir(), "hn")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			test(t, s)
+		})
+	}
+}
+
+func TestDaemonPruneKeepsCrawledDays(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s store) {
+		now := time.Now().UTC().Truncate(time.Second)
+		day := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		snapshots := []Snapshot{
+			// Saved by hn crawl, with the time of the day it is of
+			{ID: snapshotID(hn.SectionFront, day), Section: hn.SectionFront, Time: day},
+			// Saved by the daemon, one past the retention and one within it
+			{ID: snapshotID(hn.SectionTop, now.Add(-48*time.Hour)), Section: hn.SectionTop, Time: now.Add(-48 * time.Hour)},
+			{ID: snapshotID(hn.SectionTop, now), Section: hn.SectionTop, Time: now},
+		}
+		for _, snapshot := range snapshots {
+			if err := s.Save(snapshot); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		pruned, err := prune(s, []string{hn.SectionTop, hn.SectionNew, hn.SectionBest}, now.Add(-24*time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pruned != 1 {
+			t.Errorf("pruned %d snapshots, want 1", pruned)
+		}
+
+		kept, err := s.List("")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids := make([]string, len(kept))
+		for i, snapshot := range kept {
+			ids[i] = snapshot.ID
+		}
+		if want := []string{snapshots[0].ID, snapshots[2].ID}; !reflect.DeepEqual(ids, want) {
+			t.Errorf("kept %v, want %v", ids, want)
+		}
+	})
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hn/hn"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A broadcaster polls a list and sends the changes, as found by diffPosts, to
+// every subscriber, so dashboards do not each have to poll
+type broadcaster struct {
+	mutex       sync.Mutex
+	subscribers map[chan Event]bool
+}
+
+func newBroadcaster() *broadcaster {
+	return &broadcaster{subscribers: make(map[chan Event]bool)}
+}
+
+func (b *broadcaster) subscribe() chan Event {
+	events := make(chan Event, 64)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.subscribers[events] = true
+
+	return events
+}
+
+func (b *broadcaster) unsubscribe(events chan Event) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	delete(b.subscribers, events)
+}
+
+func (b *broadcaster) publish(event Event) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	// A subscriber that can not keep up misses events, rather than holding up the others
+	for events := range b.subscribers {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
+
+// poll lists a section every interval, the first poll is the baseline
+func (b *broadcaster) poll(section string, postsToFetch int, every time.Duration) {
+	var prev hn.Posts
+	for {
+		posts, err := client.FetchPosts(section, postsToFetch)
+		if err != nil {
+			slog.Error("polling failed", "section", section, "error", err)
+		} else {
+			if prev != nil {
+				for _, event := range diffPosts(prev, posts, time.Now()) {
+					b.publish(event)
+				}
+			}
+			prev = posts
+		}
+
+		time.Sleep(every)
+	}
+}
+
+// handleStream sends events as Server-Sent Events, e.g. /stream?events=entered,points
+func (s *server) handleStream(w http.ResponseWriter, r *http.Request) {
+	if s.stream == nil {
+		writeHTTPError(w, http.StatusNotFound, errors.New("streaming is not enabled"))
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeHTTPError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+
+	wanted := map[string]bool{eventEntered: true, eventDropped: true, eventPoints: true}
+	if value := r.URL.Query().Get("events"); value != "" {
+		wanted = make(map[string]bool)
+		for _, t := range strings.Split(value, ",") {
+			if t != eventEntered && t != eventDropped && t != eventPoints {
+				writeHTTPError(w, http.StatusBadRequest, fmt.Errorf("unknown event %q, must be entered, dropped or points", t))
+				return
+			}
+			wanted[t] = true
+		}
+	}
+
+	events := s.stream.subscribe()
+	defer s.stream.unsubscribe(events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// Comments keep proxies from closing an idle connection
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case event := <-events:
+			if !wanted[event.Type] {
+				continue
+			}
+
+			data, err := json.Marshal(event)
+			if err != nil {
+				slog.Error("encoding event failed", "error", err)
+				continue
+			}
+
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+		}
+
+		flusher.Flush()
+	}
+}
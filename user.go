@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hn/hn"
+	"os"
+	"time"
+)
+
+// UserHistory is what is known of a tracked user, the karma of every run and
+// the submissions and comments already seen
+type UserHistory struct {
+	User        string
+	Karma       []KarmaSample
+	Submissions []int
+	Comments    []int
+}
+
+// A KarmaSample is the karma of a user at a point in time
+type KarmaSample struct {
+	Time  time.Time
+	Karma int
+}
+
+// A UserDelta is what changed for a tracked user since the previous run
+type UserDelta struct {
+	User        hn.User
+	Time        time.Time
+	KarmaChange int
+	Submissions hn.Posts
+	Comments    []*hn.Comment
+}
+
+// maxSeen is how many submission and comment ids are kept, well beyond the first page of either
+const maxSeen = 1000
+
+func runUser(args []string) error {
+	var track bool
+	var storeURL string
+	var textFormat string
+
+	flags := flag.NewFlagSet("user", flag.ContinueOnError)
+	flags.BoolVar(&track, "track", false, "Record karma, submissions and comments in the store, and print what changed since the last run")
+	flags.StringVar(&storeURL, "store", "", "Where to keep tracked users, e.g. file:///var/lib/hn")
+	flags.StringVar(&textFormat, "text-format", hn.TextMarkdown, "Format of the about and comment text, markdown, plain or html")
+
+	clientOptions := addClientFlags(flags)
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if err := clientOptions.apply(); err != nil {
+		return err
+	}
+
+	if err := hn.ValidTextFormat(textFormat); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("usage: hn user [-track -store=file:///var/lib/hn] <name>")
+	}
+	name := flags.Arg(0)
+
+	if !track {
+		user, err := client.FetchUser(name)
+		if err != nil {
+			return err
+		}
+		user.About = hn.ConvertText(user.About, textFormat)
+
+		return writeIndentedJSON(user)
+	}
+
+	if storeURL == "" {
+		return errors.New("track requires a store, e.g. -store=file:///var/lib/hn")
+	}
+
+	s, err := openStore(storeURL)
+	if err != nil {
+		return err
+	}
+
+	delta, err := trackUser(s, name, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	delta.User.About = hn.ConvertText(delta.User.About, textFormat)
+	hn.FormatCommentText(delta.Comments, textFormat)
+
+	return writeIndentedJSON(delta)
+}
+
+// trackUser fetches a user and compares it to the previous run, the first run
+// is the baseline and has no changes
+func trackUser(s store, name string, now time.Time) (*UserDelta, error) {
+	history, err := s.LoadUser(name)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := client.FetchUser(name)
+	if err != nil {
+		return nil, err
+	}
+
+	submissions, err := client.FetchSubmissions(name)
+	if err != nil {
+		return nil, err
+	}
+
+	comments, err := client.FetchUserComments(name)
+	if err != nil {
+		return nil, err
+	}
+
+	delta := &UserDelta{User: *user, Time: now, Submissions: hn.Posts{}, Comments: []*hn.Comment{}}
+
+	first := history == nil
+	if first {
+		history = &UserHistory{User: name}
+	} else if len(history.Karma) > 0 {
+		delta.KarmaChange = user.Karma - history.Karma[len(history.Karma)-1].Karma
+	}
+
+	seen := make(map[int]bool, len(history.Submissions)+len(history.Comments))
+	for _, id := range history.Submissions {
+		seen[id] = true
+	}
+	for _, id := range history.Comments {
+		seen[id] = true
+	}
+
+	for _, post := range submissions {
+		if !seen[post.ID] {
+			history.Submissions = append(history.Submissions, post.ID)
+			if !first {
+				delta.Submissions = append(delta.Submissions, post)
+			}
+		}
+	}
+
+	for _, comment := range comments {
+		if !seen[comment.ID] {
+			history.Comments = append(history.Comments, comment.ID)
+			if !first {
+				delta.Comments = append(delta.Comments, comment)
+			}
+		}
+	}
+
+	history.Karma = append(history.Karma, KarmaSample{Time: now, Karma: user.Karma})
+	history.Submissions = lastIDs(history.Submissions, maxSeen)
+	history.Comments = lastIDs(history.Comments, maxSeen)
+
+	if err := s.SaveUser(*history); err != nil {
+		return nil, err
+	}
+
+	return delta, nil
+}
+
+func lastIDs(ids []int, n int) []int {
+	if len(ids) > n {
+		return ids[len(ids)-n:]
+	}
+	return ids
+}
+
+func writeIndentedJSON(v interface{}) error {
+	response, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(os.Stdout, string(response))
+	return err
+}
@@ -0,0 +1,84 @@
+//go:build js && wasm
+
+// Command wasm exposes the parser to JavaScript, so browser extensions and web
+// tools extract posts exactly as the command line does.
+//
+//	GOOS=js GOARCH=wasm go build -o hn.wasm ./wasm
+package main
+
+import (
+	"encoding/json"
+	"hn/hn"
+	"strings"
+	"syscall/js"
+)
+
+func main() {
+	js.Global().Set("hnParsePosts", js.FuncOf(parsePosts))
+	js.Global().Set("hnParseItem", js.FuncOf(parseItem))
+	js.Global().Set("hnParseItemID", js.FuncOf(parseItemID))
+	js.Global().Set("hnConvertText", js.FuncOf(convertText))
+
+	// Keep the functions available for as long as the page is
+	select {}
+}
+
+// result returns the value as JSON, or a JavaScript Error
+func result(value interface{}, err error) interface{} {
+	if err != nil {
+		return js.Global().Get("Error").New(err.Error())
+	}
+
+	response, err := json.Marshal(value)
+	if err != nil {
+		return js.Global().Get("Error").New(err.Error())
+	}
+
+	return string(response)
+}
+
+// parsePosts(html) parses a listing, such as the front page
+func parsePosts(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 {
+		return js.Global().Get("Error").New("usage: hnParsePosts(html)")
+	}
+
+	return result(hn.ParsePosts(strings.NewReader(args[0].String())))
+}
+
+// parseItem(html, id) parses an item page with its comments
+func parseItem(this js.Value, args []js.Value) interface{} {
+	if len(args) != 2 {
+		return js.Global().Get("Error").New("usage: hnParseItem(html, id)")
+	}
+
+	return result(hn.ParseItem(strings.NewReader(args[0].String()), args[1].Int()))
+}
+
+// parseItemID(value) parses an item id or url
+func parseItemID(this js.Value, args []js.Value) interface{} {
+	if len(args) != 1 {
+		return js.Global().Get("Error").New("usage: hnParseItemID(value)")
+	}
+
+	id, err := hn.ParseItemID(args[0].String())
+	if err != nil {
+		return js.Global().Get("Error").New(err.Error())
+	}
+
+	return id
+}
+
+// convertText(html, format) converts story or comment text to plain, markdown or html
+func convertText(this js.Value, args []js.Value) interface{} {
+	if len(args) != 2 {
+		return js.Global().Get("Error").New("usage: hnConvertText(html, format)")
+	}
+
+	format := args[1].String()
+	if err := hn.ValidTextFormat(format); err != nil {
+		return js.Global().Get("Error").New(err.Error())
+	}
+
+	return hn.ConvertText(args[0].String(), format)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"hn/hn"
+	"io"
+	"strings"
+)
+
+// writeXbar writes the xbar and SwiftBar plugin format, the title shown in the
+// menu bar followed by a clickable story per line with the comments in a sub menu
+func writeXbar(w io.Writer, posts hn.Posts) error {
+	if _, err := fmt.Fprintln(w, "HN\n---"); err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		u, err := client.StoryURL(post)
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(w, "%d. %s (%s) | href=%s\n--%s comments | href=%s\n",
+			post.Rank, xbarEscape(post.Title), countString(post.Points), u,
+			countString(post.Comments), client.ItemURL(post.ID))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "---\nRefresh | refresh=true\n")
+	return err
+}
+
+// xbarEscape keeps a title from being read as parameters
+func xbarEscape(text string) string {
+	return strings.Replace(text, "|", "¦", -1)
+}